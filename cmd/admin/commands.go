@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/httpErrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+)
+
+const generatedPasswordSize = 18
+
+var errMissingFlags = errors.New("missing required flags, see -h")
+
+var commands = []command{
+	{"create-user", "-username NAME -email EMAIL [-password PASSWORD] [-role user|admin]", "Create a user, a password is generated and printed when none is given", createUser},
+	{"grant-role", "-username NAME -role user|admin", "Change the role of a user and revoke their sessions", grantRole},
+	{"reset-password", "-username NAME [-password PASSWORD]", "Set a new password and revoke all sessions, a password is generated and printed when none is given", resetPassword},
+	{"revoke-sessions", "-username NAME", "Sign a user out everywhere", revokeSessions},
+	{"delete-article", "-slug SLUG", "Soft delete an article, restore-article brings it back", deleteArticle},
+	{"restore-article", "-slug SLUG", "Restore a deleted article", restoreArticle},
+	{"merge-tags", "-into TAG SOURCE_TAG...", "Retag the articles of the source tags and delete them", mergeTags},
+	{"recompute-counters", "", "Recount the favorites of every article", recomputeCounters},
+	{"seed", "[-seed N] [-users N] [-articles N] ... [-tokens FILE]", "Insert a deterministic dataset for benchmarks, optionally with session tokens for load tests", seedData},
+	{"export-user", "-username NAME [-out FILE]", "Write everything stored about a user as json", exportUser},
+}
+
+// Validated like the api validates registrations
+type userInput struct {
+	Username string `json:"username" binding:"required,min=4,max=255"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=255"`
+	Role     string `json:"role" binding:"oneof=user admin"`
+}
+
+type passwordInput struct {
+	Password string `json:"password" binding:"required,min=8,max=255"`
+}
+
+// Readable message of a command error, validation errors are listed field by field
+func describe(err error) string {
+	res := httpErrors.NewValidatorError(err, httpErrors.GetTranslator(""))
+	if _, ok := res.Errors["body"]; ok {
+		return err.Error()
+	}
+	messages := make([]string, 0, len(res.Errors))
+	for _, message := range res.Errors {
+		messages = append(messages, fmt.Sprint(message))
+	}
+	sort.Strings(messages)
+	return strings.Join(messages, ", ")
+}
+
+// The given password, or a generated one that is printed
+func passwordOrGenerated(password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+	generated, err := utils.GenerateSecureToken(generatedPasswordSize)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("generated password: %s\n", generated)
+	return generated, nil
+}
+
+func createUser(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	input := userInput{}
+	flags.StringVar(&input.Username, "username", "", "username of the user")
+	flags.StringVar(&input.Email, "email", "", "email of the user")
+	flags.StringVar(&input.Password, "password", "", "password, generated when empty")
+	flags.StringVar(&input.Role, "role", models.RoleUser, "role of the user")
+
+	return func(ctx context.Context, a *app) error {
+		var err error
+		if input.Password, err = passwordOrGenerated(input.Password); err != nil {
+			return err
+		}
+		if err = binding.Validator.ValidateStruct(&input); err != nil {
+			return err
+		}
+		userModel := models.User{Username: input.Username, Email: input.Email, Role: input.Role}
+		if err = userModel.SetPassword(input.Password); err != nil {
+			return err
+		}
+		if err = a.userUC.Register(ctx, &userModel); err != nil {
+			return err
+		}
+		fmt.Printf("created %s user %s (id %d)\n", userModel.Role, userModel.Username, userModel.ID)
+		return nil
+	}
+}
+
+func grantRole(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	username := flags.String("username", "", "username of the user")
+	role := flags.String("role", "", "new role, user or admin")
+
+	return func(ctx context.Context, a *app) error {
+		if *username == "" || *role == "" {
+			return errMissingFlags
+		}
+		userModel, err := a.userUC.SetRole(ctx, *username, *role)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s is now %s\n", userModel.Username, userModel.Role)
+		return nil
+	}
+}
+
+func resetPassword(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	username := flags.String("username", "", "username of the user")
+	password := flags.String("password", "", "new password, generated when empty")
+
+	return func(ctx context.Context, a *app) error {
+		if *username == "" {
+			return errMissingFlags
+		}
+		input := passwordInput{}
+		var err error
+		if input.Password, err = passwordOrGenerated(*password); err != nil {
+			return err
+		}
+		if err = binding.Validator.ValidateStruct(&input); err != nil {
+			return err
+		}
+		if err = a.userUC.SetPassword(ctx, *username, input.Password); err != nil {
+			return err
+		}
+		fmt.Printf("password of %s reset, their sessions are revoked\n", *username)
+		return nil
+	}
+}
+
+func revokeSessions(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	username := flags.String("username", "", "username of the user")
+
+	return func(ctx context.Context, a *app) error {
+		if *username == "" {
+			return errMissingFlags
+		}
+		if err := a.userUC.RevokeSessions(ctx, *username); err != nil {
+			return err
+		}
+		fmt.Printf("sessions of %s revoked\n", *username)
+		return nil
+	}
+}
+
+func deleteArticle(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	slug := flags.String("slug", "", "slug of the article")
+
+	return func(ctx context.Context, a *app) error {
+		if *slug == "" {
+			return errMissingFlags
+		}
+		if err := a.articleUC.RemoveArticle(ctx, *slug); err != nil {
+			return err
+		}
+		fmt.Printf("deleted %s\n", *slug)
+		return nil
+	}
+}
+
+func restoreArticle(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	slug := flags.String("slug", "", "slug of the article")
+
+	return func(ctx context.Context, a *app) error {
+		if *slug == "" {
+			return errMissingFlags
+		}
+		articleModel, err := a.articleUC.RestoreArticle(ctx, *slug)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("restored %s (%s)\n", articleModel.Slug, articleModel.Title)
+		return nil
+	}
+}
+
+func mergeTags(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	into := flags.String("into", "", "tag the articles get, created when missing")
+
+	return func(ctx context.Context, a *app) error {
+		if *into == "" || flags.NArg() == 0 {
+			return errMissingFlags
+		}
+		merged, err := a.articleUC.MergeTags(ctx, flags.Args(), *into)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("merged %d tags into %s\n", merged, *into)
+		return nil
+	}
+}
+
+func recomputeCounters(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	return func(ctx context.Context, a *app) error {
+		fixed, err := a.articleUC.RecomputeFavoritesCounts(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("fixed the favorites count of %d articles\n", fixed)
+		return nil
+	}
+}
+
+// Json document of export-user, secrets like password and totp hashes are left out
+type userExport struct {
+	ExportedAt time.Time `json:"exportedAt"`
+	User       struct {
+		Username    string    `json:"username"`
+		Email       string    `json:"email"`
+		Bio         string    `json:"bio"`
+		Image       *string   `json:"image"`
+		Role        string    `json:"role"`
+		TotpEnabled bool      `json:"totpEnabled"`
+		CreatedAt   time.Time `json:"createdAt"`
+		UpdatedAt   time.Time `json:"updatedAt"`
+	} `json:"user"`
+	Identities   []exportedIdentity    `json:"identities"`
+	AccessTokens []exportedAccessToken `json:"accessTokens"`
+	Following    []string              `json:"following"`
+	Articles     []exportedArticle     `json:"articles"`
+	Comments     []exportedComment     `json:"comments"`
+	Favorites    []string              `json:"favorites"`
+}
+
+type exportedIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type exportedAccessToken struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type exportedArticle struct {
+	Slug           string     `json:"slug"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Body           string     `json:"body"`
+	TagList        []string   `json:"tagList"`
+	FavoritesCount uint       `json:"favoritesCount"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	DeletedAt      *time.Time `json:"deletedAt,omitempty"`
+}
+
+type exportedComment struct {
+	ID        uint      `json:"id"`
+	ArticleID uint      `json:"articleId"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func exportUser(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	username := flags.String("username", "", "username of the user")
+	out := flags.String("out", "", "file to write, stdout when empty")
+
+	return func(ctx context.Context, a *app) error {
+		if *username == "" {
+			return errMissingFlags
+		}
+		account, err := a.userUC.Export(ctx, *username)
+		if err != nil {
+			return err
+		}
+		content, err := a.articleUC.GetUserContent(ctx, account.User.ID)
+		if err != nil {
+			return err
+		}
+
+		export := userExport{
+			ExportedAt:   time.Now().UTC(),
+			Identities:   []exportedIdentity{},
+			AccessTokens: []exportedAccessToken{},
+			Following:    []string{},
+			Articles:     []exportedArticle{},
+			Comments:     []exportedComment{},
+			Favorites:    []string{},
+		}
+		export.User.Username = account.User.Username
+		export.User.Email = account.User.Email
+		export.User.Bio = account.User.Bio
+		export.User.Image = account.User.Image
+		export.User.Role = account.User.Role
+		export.User.TotpEnabled = account.User.TotpEnabled
+		export.User.CreatedAt = account.User.CreatedAt
+		export.User.UpdatedAt = account.User.UpdatedAt
+		for _, identity := range account.Identities {
+			export.Identities = append(export.Identities, exportedIdentity{identity.Provider, identity.Subject, identity.Email, identity.CreatedAt})
+		}
+		for _, token := range account.AccessTokens {
+			export.AccessTokens = append(export.AccessTokens, exportedAccessToken{token.Name, token.ScopeList(), token.ExpiresAt, token.LastUsedAt, token.CreatedAt})
+		}
+		for _, following := range account.Followings {
+			export.Following = append(export.Following, following.Username)
+		}
+		for _, articleModel := range content.Articles {
+			tags := make([]string, 0, len(articleModel.Tags))
+			for _, tag := range articleModel.Tags {
+				tags = append(tags, tag.Tag)
+			}
+			export.Articles = append(export.Articles, exportedArticle{
+				Slug:           articleModel.Slug,
+				Title:          articleModel.Title,
+				Description:    articleModel.Description,
+				Body:           articleModel.Body,
+				TagList:        tags,
+				FavoritesCount: articleModel.FavoritesCount,
+				CreatedAt:      articleModel.CreatedAt,
+				UpdatedAt:      articleModel.UpdatedAt,
+				DeletedAt:      articleModel.DeletedAt,
+			})
+		}
+		for _, comment := range content.Comments {
+			export.Comments = append(export.Comments, exportedComment{comment.ID, comment.ArticleID, comment.Body, comment.CreatedAt})
+		}
+		for _, favorite := range content.Favorites {
+			export.Favorites = append(export.Favorites, favorite.Slug)
+		}
+
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	}
+}
@@ -0,0 +1,138 @@
+// Operational tasks against the database and redis of the api, "admin help" lists the commands
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/article"
+	articleRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/article/repository"
+	articleUsecase "github.com/gothinkster/golang-gin-realworld-example-app/internal/article/usecase"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
+	sessRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/session/repository"
+	sessUsecase "github.com/gothinkster/golang-gin-realworld-example-app/internal/session/usecase"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	userRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/user/repository"
+	userUsecase "github.com/gothinkster/golang-gin-realworld-example-app/internal/user/usecase"
+	"github.com/gothinkster/golang-gin-realworld-example-app/migrations"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/redis"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/httpErrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/mailer"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/oauth"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+)
+
+// Usecases the commands work with, the same ones the api serves
+type app struct {
+	cfg       *config.Config
+	logger    logger.Logger
+	db        *postgres.DB
+	sessUC    session.UseCase
+	userUC    user.UseCase
+	articleUC article.UseCase
+}
+
+// setup registers the flags of the command and returns what runs once they're parsed
+type command struct {
+	name    string
+	args    string
+	summary string
+	setup   func(flags *flag.FlagSet) func(ctx context.Context, a *app) error
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin <command> [flags]\n\ncommands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr, "\nrun \"admin <command> -h\" for the flags of a command")
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" {
+		usage()
+		os.Exit(2)
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == os.Args[1] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: admin %s %s\n\n%s\n\n", cmd.name, cmd.args, cmd.summary)
+		flags.PrintDefaults()
+	}
+	run := cmd.setup(flags)
+	profile := flags.String("profile", "", "config profile, reads config/config-<profile>.yml, defaults to $APP_PROFILE or local")
+	flags.Parse(os.Args[2:])
+
+	configPath := utils.GetConfigPath(*profile)
+	cfgFile, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("LoadConfig: %v", err)
+	}
+	cfg, err := config.ParseConfig(cfgFile)
+	if err != nil {
+		log.Fatalf("ParseConfig: %v", err)
+	}
+
+	appLogger := logger.NewApiLogger(cfg)
+	appLogger.InitLogger()
+	if err = httpErrors.ValidatorTranslationsInit(); err != nil {
+		appLogger.Fatalf("Validator translations: %s", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	gormDB, err := postgres.DBInit(postgres.PostgresURL(cfg), appLogger, cfg.Server.Debug, time.Duration(cfg.Postgres.SlowQueryThreshold)*time.Millisecond)
+	if err != nil {
+		appLogger.Fatalf("Postgresql init: %s", err)
+	}
+	defer gormDB.Close()
+
+	// the usecases are written for the schema of this build
+	migrator, err := postgres.NewMigrator(gormDB, migrations.FS, appLogger)
+	if err != nil {
+		appLogger.Fatalf("Migrations: %s", err)
+	}
+	if err = migrator.Check(ctx); err != nil {
+		appLogger.Fatalf("Schema check: %s", err)
+	}
+
+	redisClient := redis.RedisInit(cfg.Redis.RedisAddr, time.Duration(cfg.Redis.SlowCommandThreshold)*time.Millisecond)
+	defer redisClient.Close()
+
+	txManager := postgres.NewTxManager(gormDB)
+	articleRepo := articleRepository.NewArticleRepository(gormDB)
+	sessUC := sessUsecase.NewSessionUseCase(cfg, sessRepository.NewSessionRepository(cfg, redisClient))
+	userUC := userUsecase.NewUserUseCase(cfg,
+		userRepository.NewUserRepository(gormDB),
+		userRepository.NewUserRedisRepository(cfg, redisClient),
+		sessUC, mailer.MailerInit(cfg), oauth.OAuthInit(cfg), txManager)
+	a := &app{cfg: cfg, logger: appLogger, db: gormDB, sessUC: sessUC, userUC: userUC, articleUC: articleUsecase.NewArticleUseCase(articleRepo, txManager)}
+
+	if err = run(ctx, a); err != nil {
+		appLogger.Fatalf("%s: %s", cmd.name, describe(err))
+	}
+}
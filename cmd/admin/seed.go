@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/seed"
+)
+
+const defaultSeedPassword = "password123"
+
+func seedData(flags *flag.FlagSet) func(ctx context.Context, a *app) error {
+	opts := seed.DefaultOptions()
+	flags.Int64Var(&opts.Seed, "seed", opts.Seed, "seed of the generator, the same seed gives the same data")
+	flags.IntVar(&opts.Users, "users", opts.Users, "number of users")
+	flags.IntVar(&opts.Articles, "articles", opts.Articles, "number of articles")
+	flags.IntVar(&opts.Tags, "tags", opts.Tags, "number of distinct tags")
+	flags.IntVar(&opts.Favorites, "favorites", opts.Favorites, "number of favorites")
+	flags.IntVar(&opts.Comments, "comments", opts.Comments, "number of comments")
+	flags.IntVar(&opts.MaxFollows, "max-follows", opts.MaxFollows, "most users a user follows")
+	flags.StringVar(&opts.Prefix, "prefix", opts.Prefix, "prefix of usernames, emails and slugs")
+	password := flags.String("password", defaultSeedPassword, "password of every user")
+	tokens := flags.String("tokens", "", "csv file to write a session token of every user to")
+
+	return func(ctx context.Context, a *app) error {
+		input := passwordInput{Password: *password}
+		if err := binding.Validator.ValidateStruct(&input); err != nil {
+			return err
+		}
+		data, err := seed.Generate(opts)
+		if err != nil {
+			return err
+		}
+		// hashed once, bcrypt for every user would take minutes on large datasets
+		hashed := models.User{}
+		if err = hashed.SetPassword(input.Password); err != nil {
+			return err
+		}
+
+		started := time.Now()
+		written, err := seed.Write(ctx, a.db, data, hashed.PasswordHash)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("seeded %d users, %d follows, %d tags, %d articles, %d favorites and %d comments in %s\n",
+			len(data.Users), len(data.Follows), len(data.Tags), len(data.Articles), len(data.Favorites), len(data.Comments),
+			time.Since(started).Round(time.Millisecond))
+
+		if *tokens == "" {
+			return nil
+		}
+		return writeTokens(ctx, a, *tokens, data, written, input.Password)
+	}
+}
+
+// One line per seeded user with a session token for the Authorization header
+func writeTokens(ctx context.Context, a *app, path string, data *seed.Dataset, written *seed.Written, password string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	if err = w.Write([]string{"id", "username", "email", "password", "token"}); err != nil {
+		file.Close()
+		return err
+	}
+	for i, u := range data.Users {
+		userModel := models.User{ID: written.UserIDs[i], Username: u.Username, Email: u.Email}
+		sess, err := a.sessUC.CreateSession(ctx, &userModel, a.cfg.Session.Expire)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		record := []string{strconv.FormatUint(uint64(userModel.ID), 10), u.Username, u.Email, password, sess.Token}
+		if err = w.Write(record); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	w.Flush()
+	if err = errors.Join(w.Error(), file.Close()); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d tokens to %s\n", len(data.Users), path)
+	return nil
+}
@@ -1,48 +1,43 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
 	"log"
-	"net/http"
 	"os"
-
-	"github.com/gin-gonic/gin"
+	"time"
 
 	"github.com/gothinkster/golang-gin-realworld-example-app/config"
-	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/server"
+	"github.com/gothinkster/golang-gin-realworld-example-app/migrations"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/csrf"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/redis"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/locker"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/mailer"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/oauth"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
-
-	"github.com/opentracing/opentracing-go"
-	"github.com/uber/jaeger-client-go"
-	jaegercfg "github.com/uber/jaeger-client-go/config"
-	jaegerlog "github.com/uber/jaeger-client-go/log"
-	"github.com/uber/jaeger-lib/metrics"
 )
 
-func PromHandler(handler http.Handler) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		handler.ServeHTTP(c.Writer, c.Request)
-	}
-}
-
-func Migrate(db *postgres.DB) {
-	db.AutoMigrate(&models.Follow{})
-	db.AutoMigrate(&models.Article{})
-	db.AutoMigrate(&models.Tag{})
-	db.AutoMigrate(&models.Favorite{})
-	db.AutoMigrate(&models.ArticleUser{})
-	db.AutoMigrate(&models.Comment{})
-	db.AutoMigrate(&models.User{})
-}
-
+// Serves the api, "api migrate ..." manages the database schema instead
 func main() {
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	profile := flags.String("profile", "", "config profile, reads config/config-<profile>.yml, defaults to $APP_PROFILE or local")
+	flags.Parse(os.Args[1:])
+	args := flags.Args()
+	if len(args) > 0 && args[0] != "migrate" {
+		log.Fatalf("unknown command %q, expected none or migrate", args[0])
+	}
+	if isMigrateCreate(args) {
+		if err := migrateCreate(args[2:]); err != nil {
+			log.Fatalf("migrate create: %v", err)
+		}
+		return
+	}
 
-	configPath := utils.GetConfigPath(os.Getenv("config"))
+	configPath := utils.GetConfigPath(*profile)
 
 	cfgFile, err := config.LoadConfig(configPath)
 	if err != nil {
@@ -54,6 +49,10 @@ func main() {
 		log.Fatalf("ParseConfig: %v", err)
 	}
 
+	appLogger := logger.NewApiLogger(cfg)
+	appLogger.InitLogger()
+	appLogger.Infof("AppVersion: %s, LogLevel: %s, Mode: %s", cfg.Server.AppVersion, cfg.Logger.Level, cfg.Server.Mode)
+
 	if cfg.Server.CSRF {
 		var argCSRFKey string
 		if cfg.Server.CSRF_Key != "" {
@@ -65,64 +64,58 @@ func main() {
 	}
 
 	// postgres
-	var sslMode string
-	if cfg.Postgres.PostgresqlSSLMode {
-		sslMode = "enable"
-	} else {
-		sslMode = "disable"
-	}
-	postgresUrl := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=%s",
-		cfg.Postgres.PostgresqlUser,
-		cfg.Postgres.PostgresqlPassword,
-		cfg.Postgres.PostgresqlHost,
-		cfg.Postgres.PostgresqlPort,
-		cfg.Postgres.PostgresqlDbname,
-		sslMode,
-	)
-
-	gormDB, err := postgres.DBInit(postgresUrl)
+	postgresUrl := postgres.PostgresURL(cfg)
+	gormDB, err := postgres.DBInit(postgresUrl, appLogger, cfg.Server.Debug, time.Duration(cfg.Postgres.SlowQueryThreshold)*time.Millisecond)
 	if err != nil {
-		log.Fatalf("Postgresql init: %s", err)
+		appLogger.Fatalf("Postgresql init: %s", err)
 	} else {
-		log.Printf("Postgres connected, Status: %#v\n", gormDB.DB().Stats())
+		appLogger.Infof("Postgres connected, Status: %#v", gormDB.DB().Stats())
 	}
 	defer gormDB.Close()
 
-	Migrate(gormDB)
+	migrator, err := postgres.NewMigrator(gormDB, migrations.FS, appLogger)
+	if err != nil {
+		appLogger.Fatalf("Migrations: %s", err)
+	}
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelMigrate()
+	if len(args) > 0 {
+		if err = migrate(migrateCtx, migrator, args[1:]); err != nil {
+			appLogger.Fatalf("migrate: %s", err)
+		}
+		return
+	}
+	// replicas never change the schema on their own, it's migrated before they're rolled out
+	if err = migrator.Check(migrateCtx); err != nil {
+		appLogger.Fatalf("Schema check: %s", err)
+	}
+	cancelMigrate()
 
 	// redis
-	redis := redis.RedisInit(cfg.Redis.RedisAddr)
+	redis := redis.RedisInit(cfg.Redis.RedisAddr, time.Duration(cfg.Redis.SlowCommandThreshold)*time.Millisecond)
 	defer redis.Close()
 
 	locker := locker.LockerInit(redis, cfg)
 
-	jaegerCfgInstance := jaegercfg.Configuration{
-		ServiceName: cfg.Jaeger.ServiceName,
-		Sampler: &jaegercfg.SamplerConfig{
-			Type:  jaeger.SamplerTypeConst,
-			Param: 1,
-		},
-		Reporter: &jaegercfg.ReporterConfig{
-			LogSpans:           cfg.Jaeger.LogSpans,
-			LocalAgentHostPort: cfg.Jaeger.Host,
-		},
-	}
+	mailer := mailer.MailerInit(cfg)
+
+	oauthClient := oauth.OAuthInit(cfg)
 
-	tracer, closer, err := jaegerCfgInstance.NewTracer(
-		jaegercfg.Logger(jaegerlog.StdLogger),
-		jaegercfg.Metrics(metrics.NullFactory),
-	)
+	shutdownTracer, err := tracing.TracerInit(context.Background(), cfg)
 	if err != nil {
-		log.Fatal("cannot create tracer", err)
+		appLogger.Fatal("cannot create tracer", err)
 	}
-	log.Println("Jaeger connected")
-
-	opentracing.SetGlobalTracer(tracer)
-	defer closer.Close()
-	log.Println("Opentracing connected")
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracer(ctx); err != nil {
+			appLogger.Errorf("Tracer shutdown: %s", err)
+		}
+	}()
+	appLogger.Infof("OpenTelemetry tracing, Exporter: %s, SampleRatio: %v", cfg.Tracing.Exporter, cfg.Tracing.SampleRatio)
 
-	server := server.NewServer(cfg, gormDB, redis, locker)
+	server := server.NewServer(cfg, gormDB, redis, locker, mailer, oauthClient, appLogger)
 	if err = server.Run(); err != nil {
-		log.Fatal(err)
+		appLogger.Fatal(err)
 	}
 }
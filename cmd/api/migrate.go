@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
+)
+
+const migrateUsage = `usage: api migrate <command>
+
+commands:
+  up                 apply every pending migration
+  down [n]           roll back the last n applied migrations, 1 by default
+  to <version>       migrate up or down to version, 0 rolls back everything
+  status             list the migrations and when they were applied
+  create <name>      write empty up and down files, see -dir
+`
+
+var errMigrateUsage = errors.New("invalid migrate command")
+
+// "migrate create" only writes files, it doesn't need config or a database
+func isMigrateCreate(args []string) bool {
+	return len(args) > 1 && args[0] == "migrate" && args[1] == "create"
+}
+
+func migrateCreate(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	dir := flags.String("dir", "migrations", "directory of the migration files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return errMigrateUsage
+	}
+	files, err := postgres.CreateMigration(*dir, flags.Arg(0))
+	for _, file := range files {
+		fmt.Println("created", file)
+	}
+	return err
+}
+
+func migrate(ctx context.Context, migrator *postgres.Migrator, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return errMigrateUsage
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("%w: down expects a positive number of steps", errMigrateUsage)
+			}
+			steps = n
+		}
+		return migrator.Down(ctx, steps)
+	case "to":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: to expects a version", errMigrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("%w: to expects a version", errMigrateUsage)
+		}
+		return migrator.To(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			name, appliedAt := status.Name, "pending"
+			if name == "" {
+				name = "(unknown to this build)"
+			}
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return errMigrateUsage
+	}
+}
@@ -1,22 +1,35 @@
 package config
 
 import (
-	"errors"
+	"fmt"
 	"log"
+	"reflect"
+	"strconv"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 // App config struct
 type Config struct {
-	Server   ServerConfig
-	Postgres PostgresConfig
-	Redis    RedisConfig
-	Session  Session
-	Metrics  Metrics
-	// Logger   Logger
-	Jaeger Jaeger
+	Server        ServerConfig
+	Postgres      PostgresConfig
+	Redis         RedisConfig
+	Session       Session
+	Metrics       Metrics
+	Logger        Logger
+	Tracing       Tracing
+	Mailer        Mailer
+	PasswordReset PasswordReset
+	LoginThrottle LoginThrottle
+	TwoFactor     TwoFactor
+	OAuth         OAuth
+	RateLimit     RateLimit
+	Cors          Cors
+	Idempotency   Idempotency
+	Locker        Locker
+	Health        Health
 }
 
 // Server config struct
@@ -32,17 +45,19 @@ type ServerConfig struct {
 	Debug             bool
 	CSRF              bool
 	CSRF_Key          string
+	ProblemDetails    bool // always render errors as application/problem+json
+	RequireIfMatch    bool // article updates and deletes without If-Match are rejected
 	// SSL               bool
 }
 
-// Logger config
-// type Logger struct {
-// 	Development       bool
-// 	DisableCaller     bool
-// 	DisableStacktrace bool
-// 	Encoding          string
-// 	Level             string
-// }
+// Logger config, Encoding is 'json' | 'console'
+type Logger struct {
+	Development       bool
+	DisableCaller     bool
+	DisableStacktrace bool
+	Encoding          string
+	Level             string
+}
 
 // Postgresql config
 type PostgresConfig struct {
@@ -53,19 +68,21 @@ type PostgresConfig struct {
 	PostgresqlDbname   string
 	PostgresqlSSLMode  bool
 	PgDriver           string
+	SlowQueryThreshold int // milliseconds, slower statements are logged, zero disables it
 }
 
 // Redis config
 type RedisConfig struct {
-	RedisAddr      string
-	RedisPassword  string
-	RedisDB        string
-	RedisDefaultdb string
-	MinIdleConns   int
-	PoolSize       int
-	PoolTimeout    int
-	Password       string
-	DB             int
+	RedisAddr            string
+	RedisPassword        string
+	RedisDB              string
+	RedisDefaultdb       string
+	MinIdleConns         int
+	PoolSize             int
+	PoolTimeout          int
+	Password             string
+	DB                   int
+	SlowCommandThreshold int // milliseconds, slower commands are logged, zero disables it
 }
 
 // Session config
@@ -75,44 +92,173 @@ type Session struct {
 	Expire int
 }
 
-// Metrics config
+// Admin server config, URL is the private address serving metrics, pprof, build info,
+// the config and the log level. An empty Username leaves it without basic auth.
 type Metrics struct {
 	URL         string
 	ServiceName string
+	Username    string
+	Password    string
 }
 
-type Jaeger struct {
-	Host        string
+// OpenTelemetry tracing config, Exporter is otlp, stdout or none. Endpoint is the
+// host:port of the OTLP/HTTP collector. SampleRatio is the share of new traces
+// recorded, requests with a sampled traceparent are always recorded.
+type Tracing struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
 	ServiceName string
-	LogSpans    bool
+	SampleRatio float64
+}
+
+// Mailer config, an empty Host writes mails to the log instead of sending them
+type Mailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Password reset config
+type PasswordReset struct {
+	Prefix string
+	Expire int
+	URL    string
+}
+
+// Login throttle config, Window and LockoutDuration are in seconds, delays in milliseconds
+type LoginThrottle struct {
+	Prefix          string
+	Window          int
+	MaxAttempts     int
+	MaxIPAttempts   int
+	LockoutDuration int
+	DelayAfter      int
+	BaseDelay       int
+	MaxDelay        int
+}
+
+// Two factor auth config, ChallengeExpire is in seconds
+type TwoFactor struct {
+	Issuer            string
+	Prefix            string
+	ChallengeExpire   int
+	ChallengeAttempts int
+	RecoveryCodes     int
+}
+
+// OAuth2/OpenID Connect login config, StateExpire is in seconds
+type OAuth struct {
+	Prefix      string
+	StateExpire int
+	Providers   map[string]OAuthProvider
+}
+
+// OpenID Connect provider, endpoints are discovered from the issuer
+type OAuthProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Rate limit config, policies are applied per route group by name
+type RateLimit struct {
+	Prefix   string
+	Policies map[string]RateLimitPolicy
+}
+
+// Rate requests per Period seconds, Burst requests may be sent at once
+type RateLimitPolicy struct {
+	Rate   int
+	Period int
+	Burst  int
 }
 
-// Load config file from given path
+// Idempotency-Key config, responses are replayed for Expire seconds
+type Idempotency struct {
+	Prefix string
+	Expire int
+}
+
+// Distributed lock config in seconds, TTL is the lease held locks keep refreshing
+// and Wait how long ObtainLock retries while somebody else holds the lock
+type Locker struct {
+	TTL  int
+	Wait int
+}
+
+// Health probe config, Timeout is per dependency check and CacheTTL how long a
+// readiness report is reused, both in milliseconds. DrainDelay is how many seconds
+// the instance reports not ready on shutdown before it stops accepting requests.
+type Health struct {
+	Timeout    int
+	CacheTTL   int
+	DrainDelay int
+}
+
+// CORS config, Origins entries are exact origins, "*", wildcard subdomains
+// like "https://*.example.com" or regular expressions prefixed with "regex:",
+// which have to match the whole origin. "*" can't be used with Credentials.
+// MaxAge is in seconds.
+type Cors struct {
+	Origins       []string
+	Methods       []string
+	Headers       []string
+	ExposeHeaders []string
+	Credentials   bool
+	MaxAge        int
+}
+
+// Load config file from given path, APP_ environment variables override its values
 func LoadConfig(filename string) (*viper.Viper, error) {
 	v := viper.New()
 
 	v.SetConfigName(filename)
 	v.AddConfigPath(".")
-	v.AutomaticEnv()
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			return nil, errors.New("config file not found")
+			return nil, fmt.Errorf("config file %s.yml not found", filename)
 		}
 		return nil, err
 	}
+	if err := bindEnv(v); err != nil {
+		return nil, err
+	}
 
 	return v, nil
 }
 
-// Parse config file
+// Durations are plain numbers in the files, so APP_SERVER_READTIMEOUT=10 is read the same way
+func secondsToDurationHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	if n, err := strconv.ParseInt(data.(string), 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	return data, nil
+}
+
+// Parse config file and validate it
 func ParseConfig(v *viper.Viper) (*Config, error) {
 	var c Config
 
-	err := v.Unmarshal(&c)
+	err := v.Unmarshal(&c, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		secondsToDurationHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
 	if err != nil {
 		log.Printf("unable to decode into struct, %v", err)
 		return nil, err
 	}
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
 
 	return &c, nil
 }
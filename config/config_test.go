@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadLocal(t *testing.T) (*Config, error) {
+	t.Helper()
+	v, err := LoadConfig("config-local")
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(v)
+}
+
+func TestEnvOverrides(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "jwt")
+	require.NoError(t, os.WriteFile(secret, []byte("from-a-file\n"), 0o600))
+	t.Setenv("APP_SERVER_PORT", ":9090")
+	t.Setenv("APP_SERVER_JWTSECRETKEY_FILE", secret)
+	t.Setenv("APP_LOCKER_WAIT", "3")
+	t.Setenv("APP_CORS_ORIGINS", "https://a.example.com,https://b.example.com")
+
+	cfg, err := loadLocal(t)
+	require.NoError(t, err)
+	assert.Equal(t, ":9090", cfg.Server.Port)
+	assert.Equal(t, "from-a-file", cfg.Server.JwtSecretKey)
+	assert.Equal(t, 3, cfg.Locker.Wait)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.Cors.Origins)
+	assert.Equal(t, "APP_SERVER_CSRF_KEY", EnvName("server.csrf_key"))
+}
+
+func TestEnvFileErrors(t *testing.T) {
+	t.Setenv("APP_POSTGRES_POSTGRESQLPASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("APP_REDIS_PASSWORD", "secret")
+	t.Setenv("APP_REDIS_PASSWORD_FILE", "/run/secrets/redis")
+
+	_, err := loadLocal(t)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 2)
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("APP_SERVER_JWTSECRETKEY", " ")
+	t.Setenv("APP_SERVER_PORT", "8080")
+	t.Setenv("APP_SERVER_READTIMEOUT", "0")
+	t.Setenv("APP_LOGGER_LEVEL", "verbose")
+	t.Setenv("APP_TRACING_SAMPLERATIO", "2")
+	t.Setenv("APP_METRICS_URL", "0.0.0.0:7070")
+	t.Setenv("APP_CORS_ORIGINS", "*")
+
+	_, err := loadLocal(t)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`server.Port must be a host:port address, got "8080"`,
+		"server.JwtSecretKey is required",
+		"server.ReadTimeout must be greater than 0, got 0",
+		`logger.Level must be one of debug, info, warn, error, dpanic, panic, fatal, got "verbose"`,
+		`metrics.URL "0.0.0.0:7070" isn't a loopback address, set metrics.Username and metrics.Password`,
+		"tracing.SampleRatio must be between 0 and 1, got 2",
+		`cors.Origins can't contain "*" when cors.Credentials is set, list the origins`,
+	}, validationErr.Problems)
+
+	for _, profile := range []string{"config-local", "config-docker"} {
+		v, err := LoadConfig(profile)
+		require.NoError(t, err)
+		t.Setenv("APP_SERVER_JWTSECRETKEY", "secret")
+		t.Setenv("APP_SERVER_PORT", ":8080")
+		t.Setenv("APP_SERVER_READTIMEOUT", "10")
+		t.Setenv("APP_LOGGER_LEVEL", "info")
+		t.Setenv("APP_TRACING_SAMPLERATIO", "1")
+		// the address of the profile, config-docker binds to every interface with credentials
+		t.Setenv("APP_METRICS_URL", "")
+		t.Setenv("APP_CORS_ORIGINS", "http://localhost:4100")
+		_, err = ParseConfig(v)
+		assert.NoError(t, err, profile)
+	}
+
+	t.Setenv("APP_METRICS_URL", ":7070")
+	t.Setenv("APP_METRICS_USERNAME", "prometheus")
+	t.Setenv("APP_METRICS_PASSWORD", "secret")
+	_, err = loadLocal(t)
+	assert.NoError(t, err)
+}
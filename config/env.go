@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Every field can be set with an environment variable named after its key,
+// APP_SERVER_JWTSECRETKEY sets server.JwtSecretKey. A variable with the _FILE
+// suffix names a file holding the value instead, like secrets mounted by Kubernetes.
+const (
+	envPrefix     = "APP"
+	envFileSuffix = "_FILE"
+)
+
+// Name of the environment variable of a config key like "server.port"
+func EnvName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Bind the variables of every field and read the files of the _FILE ones
+func bindEnv(v *viper.Viper) error {
+	var problems []string
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		name := EnvName(key)
+		if err := v.BindEnv(key, name); err != nil {
+			return err
+		}
+
+		path, ok := os.LookupEnv(name + envFileSuffix)
+		if !ok {
+			continue
+		}
+		if _, ok = os.LookupEnv(name); ok {
+			problems = append(problems, fmt.Sprintf("%s and %s%s are both set, set one of them", name, name, envFileSuffix))
+			continue
+		}
+		value, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s%s: %v", name, envFileSuffix, err))
+			continue
+		}
+		// files written by editors and echo end with a newline the secret doesn't have
+		v.Set(key, strings.TrimRight(string(value), "\r\n"))
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Keys of the fields of t, maps like the oauth providers are set as a whole in the file
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.ToLower(prefix + field.Name)
+		switch {
+		case field.Type.Kind() == reflect.Map:
+		case field.Type.Kind() == reflect.Struct:
+			keys = append(keys, configKeys(field.Type, key+".")...)
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
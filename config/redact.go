@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// Config as a map for dumping it, secrets and the passwords of URLs are replaced
+func Redacted(cfg *Config) (map[string]interface{}, error) {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var dump map[string]interface{}
+	if err = json.Unmarshal(raw, &dump); err != nil {
+		return nil, err
+	}
+	redactMap(dump)
+	return dump, nil
+}
+
+func redactMap(values map[string]interface{}) {
+	for name, value := range values {
+		if isSecret(name) {
+			if value != "" && value != nil {
+				values[name] = redacted
+			}
+			continue
+		}
+		values[name] = redactValue(value)
+	}
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redactMap(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	case string:
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+	}
+	return value
+}
+
+// passwords, secrets and keys like JwtSecretKey or CSRF_Key
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "password") || strings.Contains(name, "secret") || strings.HasSuffix(name, "key")
+}
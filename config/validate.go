@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Every problem of a config, reported together so they can all be fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+var (
+	logLevels       = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+	logEncodings    = []string{"json", "console"}
+	tracerExporters = []string{"otlp", "stdout", "none", ""}
+)
+
+// checks collects the problems of the fields it's given
+type checks []string
+
+func (c *checks) add(format string, args ...interface{}) {
+	*c = append(*c, fmt.Sprintf(format, args...))
+}
+
+func (c *checks) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		c.add("%s is required", field)
+	}
+}
+
+func (c *checks) positive(field string, value int64) {
+	if value <= 0 {
+		c.add("%s must be greater than 0, got %d", field, value)
+	}
+}
+
+func (c *checks) notNegative(field string, value int64) {
+	if value < 0 {
+		c.add("%s must not be negative, got %d", field, value)
+	}
+}
+
+func (c *checks) oneOf(field, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	c.add("%s must be one of %s, got %q", field, strings.Join(allowed, ", "), value)
+}
+
+// host:port like ":8080" or "127.0.0.1:7070"
+func (c *checks) address(field, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		c.add("%s must be a host:port address, got %q", field, value)
+		return
+	}
+	c.port(field, port)
+}
+
+func (c *checks) port(field, value string) {
+	if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+		c.add("%s must be a port between 1 and 65535, got %q", field, value)
+	}
+}
+
+// Check the values the app can't run with, the error lists every problem found
+func (cfg *Config) Validate() error {
+	var c checks
+
+	c.required("server.AppName", cfg.Server.AppName)
+	c.address("server.Port", cfg.Server.Port)
+	c.required("server.JwtSecretKey", cfg.Server.JwtSecretKey)
+	c.positive("server.ReadTimeout", int64(cfg.Server.ReadTimeout))
+	c.positive("server.WriteTimeout", int64(cfg.Server.WriteTimeout))
+	c.positive("server.CtxDefaultTimeout", int64(cfg.Server.CtxDefaultTimeout))
+
+	c.oneOf("logger.Level", cfg.Logger.Level, logLevels)
+	c.oneOf("logger.Encoding", cfg.Logger.Encoding, logEncodings)
+
+	c.required("postgres.PostgresqlHost", cfg.Postgres.PostgresqlHost)
+	c.port("postgres.PostgresqlPort", cfg.Postgres.PostgresqlPort)
+	c.required("postgres.PostgresqlUser", cfg.Postgres.PostgresqlUser)
+	c.required("postgres.PostgresqlDbname", cfg.Postgres.PostgresqlDbname)
+	c.notNegative("postgres.SlowQueryThreshold", int64(cfg.Postgres.SlowQueryThreshold))
+
+	c.address("redis.RedisAddr", cfg.Redis.RedisAddr)
+	c.notNegative("redis.SlowCommandThreshold", int64(cfg.Redis.SlowCommandThreshold))
+
+	c.required("session.Prefix", cfg.Session.Prefix)
+	c.positive("session.Expire", int64(cfg.Session.Expire))
+
+	if cfg.Metrics.URL != "" {
+		c.address("metrics.URL", cfg.Metrics.URL)
+		// pprof, the config and the log level mustn't be open to the network
+		if !isLoopback(cfg.Metrics.URL) && (cfg.Metrics.Username == "" || cfg.Metrics.Password == "") {
+			c.add("metrics.URL %q isn't a loopback address, set metrics.Username and metrics.Password", cfg.Metrics.URL)
+		}
+	}
+	if cfg.Metrics.Username != "" {
+		c.required("metrics.Password", cfg.Metrics.Password)
+	}
+
+	c.oneOf("tracing.Exporter", cfg.Tracing.Exporter, tracerExporters)
+	if cfg.Tracing.Exporter == "otlp" {
+		c.address("tracing.Endpoint", cfg.Tracing.Endpoint)
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		c.add("tracing.SampleRatio must be between 0 and 1, got %v", cfg.Tracing.SampleRatio)
+	}
+
+	if cfg.Mailer.Host != "" {
+		c.port("mailer.Port", strconv.Itoa(cfg.Mailer.Port))
+		c.required("mailer.From", cfg.Mailer.From)
+	}
+
+	c.positive("passwordReset.Expire", int64(cfg.PasswordReset.Expire))
+	c.positive("loginThrottle.Window", int64(cfg.LoginThrottle.Window))
+	c.positive("twoFactor.ChallengeExpire", int64(cfg.TwoFactor.ChallengeExpire))
+	c.positive("oauth.StateExpire", int64(cfg.OAuth.StateExpire))
+	for _, name := range sortedKeys(cfg.OAuth.Providers) {
+		provider := cfg.OAuth.Providers[name]
+		c.required("oauth.Providers."+name+".Issuer", provider.Issuer)
+		c.required("oauth.Providers."+name+".ClientID", provider.ClientID)
+		c.required("oauth.Providers."+name+".RedirectURL", provider.RedirectURL)
+	}
+	for _, name := range sortedKeys(cfg.RateLimit.Policies) {
+		policy := cfg.RateLimit.Policies[name]
+		c.positive("rateLimit.Policies."+name+".Rate", int64(policy.Rate))
+		c.positive("rateLimit.Policies."+name+".Period", int64(policy.Period))
+		c.notNegative("rateLimit.Policies."+name+".Burst", int64(policy.Burst))
+	}
+	c.positive("idempotency.Expire", int64(cfg.Idempotency.Expire))
+	if cfg.Cors.Credentials {
+		for _, origin := range cfg.Cors.Origins {
+			// any site could make requests with the cookies and credentials of its visitors
+			if origin == "*" {
+				c.add(`cors.Origins can't contain "*" when cors.Credentials is set, list the origins`)
+			}
+		}
+	}
+
+	c.notNegative("locker.TTL", int64(cfg.Locker.TTL))
+	c.notNegative("locker.Wait", int64(cfg.Locker.Wait))
+	c.positive("health.Timeout", int64(cfg.Health.Timeout))
+	c.notNegative("health.CacheTTL", int64(cfg.Health.CacheTTL))
+	c.notNegative("health.DrainDelay", int64(cfg.Health.DrainDelay))
+
+	if len(c) > 0 {
+		return &ValidationError{Problems: c}
+	}
+	return nil
+}
+
+// Whether a host:port only listens on the local machine, an empty host listens on every interface
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// map keys in order, so the problems are listed the same way every time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,43 +1,99 @@
 package http
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gosimple/slug"
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/article"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
-	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/httpErrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/locker"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
-	"github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type articleHandlers struct {
+	cfg         *config.Config
 	articleRepo article.Repository
 	articleUc   article.UseCase
 	locker      locker.Locker
 }
 
-func NewArticleHandlers(articleRepo article.Repository, articleUc article.UseCase, locker locker.Locker) article.Handlers {
-	return &articleHandlers{articleRepo, articleUc, locker}
+func NewArticleHandlers(cfg *config.Config, articleRepo article.Repository, articleUc article.UseCase, locker locker.Locker) article.Handlers {
+	return &articleHandlers{cfg, articleRepo, articleUc, locker}
+}
+
+// Entity tag of an article response, its version followed by a hash of the response.
+// The hash changes with everything the viewer sees, like favorited or the author's
+// profile, and the version is what If-Match compares.
+func articleETag(articleModel models.Article, response ArticleResponse) string {
+	body, _ := json.Marshal(response)
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%s"`, articleModel.Version, hex.EncodeToString(sum[:16]))
+}
+
+// Version of an article etag, ok is false for tags articleETag didn't make
+func parseArticleETag(etag string) (version uint, ok bool) {
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+	versionPart, _, _ := strings.Cut(etag[1:len(etag)-1], "-")
+	v, err := strconv.ParseUint(versionPart, 10, 32)
+	if err != nil || v == 0 {
+		return 0, false
+	}
+	return uint(v), true
+}
+
+// Version an update or delete is based on, from If-Match, 0 when any version will do.
+// Only the version part of the etags counts, favorites in between don't conflict with edits.
+func (h articleHandlers) ifMatchVersion(c *gin.Context) (uint, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if h.cfg.Server.RequireIfMatch {
+			return 0, article.ErrVersionRequired
+		}
+		return 0, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	var version uint
+	for _, etag := range strings.Split(header, ",") {
+		// weak tags never match If-Match, several versions can't all be current
+		v, ok := parseArticleETag(strings.TrimSpace(etag))
+		if !ok || (version != 0 && v != version) {
+			return 0, article.ErrVersionMismatch
+		}
+		version = v
+	}
+	return version, nil
 }
 
 func (h articleHandlers) ArticleList() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleList")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 		tag := c.Query("tag")
 		author := c.Query("author")
 		favorited := c.Query("favorited")
 		pagination, err := utils.GetPaginationFromCtx(c)
+		if err != nil {
+			c.Error(article.ErrInvalidPagination)
+			return
+		}
 		articleModels, modelCount, err := h.articleUc.GetArticles(ctx, tag, author, favorited, pagination)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("articles", errors.New("Invalid param")))
+			c.Error(err)
 			return
 		}
 
@@ -49,35 +105,44 @@ func (h articleHandlers) ArticleList() gin.HandlerFunc {
 
 func (h articleHandlers) ArticleRetrieve() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleRetrieve")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		slug := c.Param("slug")
 		articleModel, err := h.articleUc.GetArticle(ctx, slug)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("articles", errors.New("Invalid slug")))
+			c.Error(err)
 			return
 		}
 		serializer := ArticleSerializer{ctx, h.articleRepo, articleModel}
-		c.JSON(http.StatusOK, gin.H{"article": serializer.Response()})
+		response := serializer.Response()
+		etag := articleETag(articleModel, response)
+		c.Header("ETag", etag)
+		// favorited and following depend on who is asking
+		c.Header("Vary", "Authorization")
+		if utils.ETagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"article": response})
 	}
 }
 
 func (h articleHandlers) ArticleFeed() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleFeed")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 		pagination, err := utils.GetPaginationFromCtx(c)
+		if err != nil {
+			c.Error(article.ErrInvalidPagination)
+			return
+		}
 		myUserModel := c.MustGet("my_user_model").(models.User)
 		if myUserModel.ID == 0 {
-			c.AbortWithError(http.StatusUnauthorized, errors.New("{error : \"Require auth!\"}"))
+			c.Error(apperrors.Unauthorized("auth", errors.New("Require auth!")))
 			return
 		}
 		articleModels, modelCount, err := h.articleUc.GetFeeds(ctx, myUserModel, pagination)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("articles", errors.New("Invalid param")))
+			c.Error(err)
 			return
 		}
 		serializer := ArticlesSerializer{ctx, h.articleRepo, articleModels}
@@ -87,21 +152,24 @@ func (h articleHandlers) ArticleFeed() gin.HandlerFunc {
 
 func (h articleHandlers) ArticleCreate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleCreate")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		myUserModel := c.MustGet("my_user_model").(models.User)
 		articleModelValidator := NewArticleModelValidator()
 		if err := articleModelValidator.Verify(c); err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewValidatorError(err))
+			c.Error(apperrors.Validation("article", err))
 			return
 		}
 
 		articleSlug := slug.Make(articleModelValidator.Article.Title)
 		lockKey := fmt.Sprintf("article:slug-%s", articleSlug)
-		lock := h.locker.ObtainLock(ctx, lockKey)
+		lock, err := h.locker.ObtainLock(ctx, lockKey)
+		if err != nil {
+			c.Error(locker.AppError(err))
+			return
+		}
 		defer lock.Release(ctx)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("lockToken", lock.Token()))
 
 		articleModel, err := h.articleUc.CreateArticle(ctx, &models.Article{
 			Slug:        articleSlug,
@@ -111,7 +179,7 @@ func (h articleHandlers) ArticleCreate() gin.HandlerFunc {
 			AuthorID:    myUserModel.ID,
 		}, articleModelValidator.Article.Tags)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewValidatorError(err))
+			c.Error(err)
 			return
 		}
 
@@ -122,22 +190,30 @@ func (h articleHandlers) ArticleCreate() gin.HandlerFunc {
 
 func (h articleHandlers) ArticleUpdate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleUpdate")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 		articleSlug := c.Param("slug")
 		articleModelValidator := NewArticlePartialModelValidator()
 		if err := articleModelValidator.Verify(c); err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewValidatorError(err))
+			c.Error(apperrors.Validation("article", err))
 			return
 		}
 
 		myUserModel := c.MustGet("my_user_model").(models.User)
+		version, err := h.ifMatchVersion(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
 
 		// apply lock to new slug to prevent collision
 		lockKey := fmt.Sprintf("article:slug-%s", articleSlug)
-		lock := h.locker.ObtainLock(ctx, lockKey)
+		lock, err := h.locker.ObtainLock(ctx, lockKey)
+		if err != nil {
+			c.Error(locker.AppError(err))
+			return
+		}
 		defer lock.Release(ctx)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("lockToken", lock.Token()))
 
 		articleModel, err := h.articleUc.UpdateArticle(ctx, articleSlug, &models.Article{
 			Slug:        slug.Make(articleModelValidator.Article.Title),
@@ -145,29 +221,41 @@ func (h articleHandlers) ArticleUpdate() gin.HandlerFunc {
 			Description: articleModelValidator.Article.Description,
 			Body:        articleModelValidator.Article.Body,
 			AuthorID:    myUserModel.ID,
+			Version:     version,
 		}, articleModelValidator.Article.Tags)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewError("database", err))
+			c.Error(err)
 			return
 		}
 		serializer := ArticleSerializer{ctx, h.articleRepo, *articleModel}
-		c.JSON(http.StatusOK, gin.H{"article": serializer.Response()})
+		response := serializer.Response()
+		c.Header("ETag", articleETag(*articleModel, response))
+		c.JSON(http.StatusOK, gin.H{"article": response})
 	}
 }
 
 func (h articleHandlers) ArticleDelete() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleDelete")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
+		version, err := h.ifMatchVersion(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
 		slug := c.Param("slug")
 		lockKey := fmt.Sprintf("article:slug-%s", slug)
-		lock := h.locker.ObtainLock(ctx, lockKey)
+		lock, err := h.locker.ObtainLock(ctx, lockKey)
+		if err != nil {
+			c.Error(locker.AppError(err))
+			return
+		}
 		defer lock.Release(ctx)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("lockToken", lock.Token()))
 
-		if err := h.articleUc.DeleteArticle(ctx, slug); err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("articles", errors.New("Invalid slug")))
+		myUserModel := c.MustGet("my_user_model").(models.User)
+		if err := h.articleUc.DeleteArticle(ctx, slug, myUserModel.ID, version); err != nil {
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"article": "Delete success"})
@@ -176,50 +264,49 @@ func (h articleHandlers) ArticleDelete() gin.HandlerFunc {
 
 func (h articleHandlers) ArticleFavorite() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleFavorite")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		articleSlug := c.Param("slug")
 		myUserModel := c.MustGet("my_user_model").(models.User)
 		articleModel, err := h.articleUc.CreateFavorite(ctx, articleSlug, myUserModel.ID)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("articles", errors.New("Invalid slug")))
+			c.Error(err)
 			return
 		}
 		serializer := ArticleSerializer{ctx, h.articleRepo, *articleModel}
-		c.JSON(http.StatusOK, gin.H{"article": serializer.Response()})
+		response := serializer.Response()
+		c.Header("ETag", articleETag(*articleModel, response))
+		c.JSON(http.StatusOK, gin.H{"article": response})
 	}
 }
 
 func (h articleHandlers) ArticleUnfavorite() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleUnfavorite")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		slug := c.Param("slug")
 		myUserModel := c.MustGet("my_user_model").(models.User)
 		articleModel, err := h.articleUc.DeleteFavorite(ctx, slug, myUserModel.ID)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("articles", err))
+			c.Error(err)
 			return
 		}
 		serializer := ArticleSerializer{ctx, h.articleRepo, *articleModel}
-		c.JSON(http.StatusOK, gin.H{"article": serializer.Response()})
+		response := serializer.Response()
+		c.Header("ETag", articleETag(*articleModel, response))
+		c.JSON(http.StatusOK, gin.H{"article": response})
 	}
 }
 
 func (h articleHandlers) ArticleCommentList() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleCommentList")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		slug := c.Param("slug")
 		comments, err := h.articleUc.GetCommentsByArticle(ctx, slug)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("comments", err))
+			c.Error(err)
+			return
 		}
 		serializer := CommentsSerializer{ctx, comments}
 		c.JSON(http.StatusOK, gin.H{"comments": serializer.Response()})
@@ -228,16 +315,14 @@ func (h articleHandlers) ArticleCommentList() gin.HandlerFunc {
 
 func (h articleHandlers) ArticleCommentCreate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleCommentCreate")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		slug := c.Param("slug")
 		myUserModel := c.MustGet("my_user_model").(models.User)
 
 		commentModelValidator := NewCommentModelValidator()
 		if err := commentModelValidator.Verify(c); err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewValidatorError(err))
+			c.Error(apperrors.Validation("comment", err))
 			return
 		}
 
@@ -246,7 +331,7 @@ func (h articleHandlers) ArticleCommentCreate() gin.HandlerFunc {
 		})
 
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, err)
+			c.Error(err)
 			return
 		}
 		serializer := CommentSerializer{ctx, *commentModel}
@@ -256,20 +341,16 @@ func (h articleHandlers) ArticleCommentCreate() gin.HandlerFunc {
 
 func (h articleHandlers) ArticleCommentDelete() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.ArticleCommentDelete")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		id64, err := strconv.ParseUint(c.Param("id"), 10, 32)
-		myUserModel := c.MustGet("my_user_model").(models.User)
-		id := uint(id64)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("comment", errors.New("Invalid id")))
+			c.Error(article.ErrCommentNotFound)
 			return
 		}
-		err = h.articleUc.DeleteComment(ctx, myUserModel.ID, []uint{id})
-		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("comment", err))
+		myUserModel := c.MustGet("my_user_model").(models.User)
+		if err = h.articleUc.DeleteComment(ctx, myUserModel.ID, uint(id64)); err != nil {
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"comment": "Delete success"})
@@ -278,13 +359,11 @@ func (h articleHandlers) ArticleCommentDelete() gin.HandlerFunc {
 
 func (h articleHandlers) TagList() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "article.TagList")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
-		tagModels, err := h.articleRepo.GetTags()
+		tagModels, err := h.articleRepo.GetTags(ctx)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("articles", err))
+			c.Error(err)
 			return
 		}
 		serializer := TagsSerializer{ctx, tagModels}
@@ -5,13 +5,14 @@ import (
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/article"
 )
 
-func ArticlesRouteRegister(router *gin.RouterGroup, h article.Handlers) {
-	router.POST("/", h.ArticleCreate())
+// idempotent makes the creating endpoints safe to retry with an Idempotency-Key
+func ArticlesRouteRegister(router *gin.RouterGroup, h article.Handlers, idempotent gin.HandlerFunc) {
+	router.POST("/", idempotent, h.ArticleCreate())
 	router.PUT("/:slug", h.ArticleUpdate())
 	router.DELETE("/:slug", h.ArticleDelete())
 	router.POST("/:slug/favorite", h.ArticleFavorite())
 	router.DELETE("/:slug/favorite", h.ArticleUnfavorite())
-	router.POST("/:slug/comments", h.ArticleCommentCreate())
+	router.POST("/:slug/comments", idempotent, h.ArticleCommentCreate())
 	router.DELETE("/:slug/comments/:id", h.ArticleCommentDelete())
 }
 
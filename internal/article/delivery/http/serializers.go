@@ -91,7 +91,7 @@ func (s *ArticleSerializer) Response() ArticleResponse {
 
 	articleUserModel := s.articleRepo.GetArticleUser(s.C, myUserModel.ID)
 	response.Favorite = s.articleRepo.IsArticleFavoriteBy(s.C, articleUserModel.ID, s.ID)
-	response.FavoritesCount = s.articleRepo.ArticleFavoritesCount(s.C, s.ID)
+	response.FavoritesCount = s.FavoritesCount
 
 	response.Tags = make([]string, 0)
 	sortTags(s.Tags)
@@ -137,11 +137,13 @@ type CommentResponse struct {
 }
 
 func (s *CommentSerializer) Response() CommentResponse {
+	authorSerializer := ArticleUserSerializer{s.C, s.Author}
 	response := CommentResponse{
 		ID:        s.ID,
 		Body:      s.Body,
 		CreatedAt: s.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
 		UpdatedAt: s.UpdatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		Author:    authorSerializer.Response(),
 	}
 	return response
 }
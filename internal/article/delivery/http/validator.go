@@ -1,9 +1,8 @@
 package http
 
 import (
-	"log"
-
 	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
 )
 
@@ -23,7 +22,7 @@ func NewArticleModelValidator() ArticleModelValidator {
 func (s *ArticleModelValidator) Verify(c *gin.Context) error {
 	err := utils.ApplyGinValidator(c, s)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(utils.GetRequestCtx(c)).Debug(err)
 		return err
 	}
 	return nil
@@ -34,14 +33,14 @@ type ArticlePartialModelValidator struct {
 		Title       string   `form:"title" json:"title" binding:"omitempty,min=4"`
 		Description string   `form:"description" json:"description" binding:"omitempty,max=2048"`
 		Body        string   `form:"body" json:"body" binding:"omitempty,max=2048"`
-		Tags        []string `form:"tagList" json:"omitempty,tagList"`
+		Tags        []string `form:"tagList" json:"tagList"`
 	} `json:"article"`
 }
 
 func (s *ArticlePartialModelValidator) Verify(c *gin.Context) error {
 	err := utils.ApplyGinValidator(c, s)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(utils.GetRequestCtx(c)).Debug(err)
 		return err
 	}
 	return nil
@@ -12,14 +12,23 @@ type Repository interface {
 	FindOneArticle(c context.Context, condition interface{}) (models.Article, error)
 	GetArticleFeed(c context.Context, userId uint, limit, offset int) ([]models.Article, int, error)
 	SaveOne(ctx context.Context, data interface{}) error
-	Update(c context.Context, data *models.Article) error
-	DeleteArticleModel(c context.Context, condition interface{}) error
+	Update(c context.Context, data *models.Article) (bool, error)
+	DeleteArticleModel(c context.Context, condition interface{}) (bool, error)
 	UpsertTags(ctx context.Context, tags []string) ([]models.Tag, error)
 	ArticleFavoritesCount(c context.Context, articleId uint) uint
 	IsArticleFavoriteBy(c context.Context, userId uint, articleId uint) bool
-	SetFavorite(ctx context.Context, articleId, userId uint) error
-	RemoveFavorite(ctx context.Context, articleId, userId uint) error
+	SetFavorite(ctx context.Context, articleId, userId uint) (bool, error)
+	RemoveFavorite(ctx context.Context, articleId, userId uint) (bool, error)
+	RecomputeFavoritesCounts(ctx context.Context) (int64, error)
+	SoftDeleteArticle(ctx context.Context, articleId uint) error
+	RestoreArticle(ctx context.Context, slug string) (bool, error)
+	FindTags(ctx context.Context, tags []string) ([]models.Tag, error)
+	MergeTags(ctx context.Context, sourceIds []uint, targetId uint) error
+	FindArticlesByAuthor(ctx context.Context, authorId uint) ([]models.Article, error)
+	FindFavoritedArticles(ctx context.Context, articleUserId uint) ([]models.Article, error)
+	FindCommentsByAuthor(ctx context.Context, authorId uint) ([]models.Comment, error)
+	FindOneComment(ctx context.Context, condition interface{}) (models.Comment, error)
 	DeleteComment(ctx context.Context, condition interface{}) error
 	GetArticleComments(ctx context.Context, article models.Article) ([]models.Comment, error)
-	GetTags() ([]models.Tag, error)
+	GetTags(ctx context.Context) ([]models.Tag, error)
 }
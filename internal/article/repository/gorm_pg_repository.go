@@ -7,7 +7,8 @@ import (
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	UserRepo "github.com/gothinkster/golang-gin-realworld-example-app/internal/user/repository"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
-	"github.com/opentracing/opentracing-go"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
+	"github.com/jinzhu/gorm"
 )
 
 type articleRepo struct {
@@ -20,14 +21,14 @@ func NewArticleRepository(db *postgres.DB) article.Repository {
 }
 
 func (r *articleRepo) GetArticleUser(c context.Context, userID uint) models.ArticleUser {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.GetArticleUser")
-	defer span.Finish()
+	c, span := tracing.Start(c, "article.articleRepo.GetArticleUser")
+	defer span.End()
 	var articleUserModel models.ArticleUser
 	var userModel models.User
 	if userID == 0 {
 		return articleUserModel
 	}
-	db := r.db
+	db := postgres.WithContext(c, r.db)
 	db.Where(&models.User{ID: userID}).First(&userModel)
 	db.Where(&models.ArticleUser{
 		UserID: userID,
@@ -38,25 +39,34 @@ func (r *articleRepo) GetArticleUser(c context.Context, userID uint) models.Arti
 }
 
 func (r *articleRepo) FindOneArticle(c context.Context, condition interface{}) (models.Article, error) {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.FindOneArticle")
-	defer span.Finish()
+	c, span := tracing.Start(c, "article.articleRepo.FindOneArticle")
+	defer span.End()
 
-	db := r.db
+	db := postgres.WithContext(c, r.db)
 	var model models.Article
-	tx := db.Begin()
-	tx.Where(condition).First(&model)
-	tx.Model(&model).Related(&model.Author, "Author")
-	tx.Model(&model.Author).Related(&model.Author.User, "UserModelID")
-	tx.Model(&model).Related(&model.Tags, "Tags")
-	err := tx.Commit().Error
+	if err := db.Where(condition).First(&model).Error; err != nil {
+		return model, err
+	}
+	err := loadArticleRelations(db, &model)
 	return model, err
 }
 
+// Load the author, their user and the tags of an article
+func loadArticleRelations(db *postgres.DB, model *models.Article) error {
+	if err := db.Model(model).Related(&model.Author, "Author").Error; err != nil {
+		return err
+	}
+	if err := db.Model(&model.Author).Related(&model.Author.User).Error; err != nil {
+		return err
+	}
+	return db.Model(model).Related(&model.Tags, "Tags").Error
+}
+
 func (r *articleRepo) ArticleFavoritesCount(c context.Context, articleId uint) uint {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.ArticleFavoritesCount")
-	defer span.Finish()
+	c, span := tracing.Start(c, "article.articleRepo.ArticleFavoritesCount")
+	defer span.End()
 
-	db := r.db
+	db := postgres.WithContext(c, r.db)
 	var count uint
 	db.Model(&models.Favorite{}).Where(models.Favorite{
 		FavoriteID: articleId,
@@ -65,10 +75,10 @@ func (r *articleRepo) ArticleFavoritesCount(c context.Context, articleId uint) u
 }
 
 func (r *articleRepo) IsArticleFavoriteBy(c context.Context, userId uint, articleId uint) bool {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.IsArticleFavoriteBy")
-	defer span.Finish()
+	c, span := tracing.Start(c, "article.articleRepo.IsArticleFavoriteBy")
+	defer span.End()
 
-	db := r.db
+	db := postgres.WithContext(c, r.db)
 	var favorite models.Favorite
 	db.Where(models.Favorite{
 		FavoriteID:   articleId,
@@ -78,171 +88,387 @@ func (r *articleRepo) IsArticleFavoriteBy(c context.Context, userId uint, articl
 }
 
 func (r *articleRepo) FindManyArticle(ctx context.Context, tag, author, favorited string, limit, offset int) ([]models.Article, int, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.articleRepo.FindManyArticle")
-	defer span.Finish()
-	db := r.db
+	ctx, span := tracing.Start(ctx, "article.articleRepo.FindManyArticle")
+	defer span.End()
+	db := postgres.WithContext(ctx, r.db)
 	var articleModels []models.Article
 	var count int
 
-	tx := db.Begin()
 	if tag != "" {
 		var tagModel models.Tag
-		tx.Where(models.Tag{Tag: tag}).First(&tagModel)
-		if tagModel.ID != 0 {
-			tx.Model(&tagModel).Offset(offset).Limit(limit).Related(&articleModels, "Articles")
-			count = tx.Model(&tagModel).Association("Articles").Count()
+		if err := db.Where(models.Tag{Tag: tag}).First(&tagModel).Error; postgres.IsRecordNotFoundError(err) {
+			return articleModels, 0, nil
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if err := db.Model(&tagModel).Offset(offset).Limit(limit).Related(&articleModels, "Articles").Error; err != nil {
+			return nil, 0, err
+		}
+		association := db.Model(&tagModel).Association("Articles")
+		if count = association.Count(); association.Error != nil {
+			return nil, 0, association.Error
 		}
 	} else if author != "" {
-		var userModel models.User
-		tx.Where(models.User{Username: author}).First(&userModel)
-		articleUserModel := r.GetArticleUser(ctx, userModel.ID)
-
-		if articleUserModel.ID != 0 {
-			count = tx.Model(&articleUserModel).Association("Articles").Count()
-			tx.Model(&articleUserModel).Offset(offset).Limit(limit).Related(&articleModels, "Articles")
+		articleUserModel, found, err := r.findArticleUserByUsername(db, author)
+		if err != nil || !found {
+			return articleModels, 0, err
+		}
+		association := db.Model(&articleUserModel).Association("Articles")
+		if count = association.Count(); association.Error != nil {
+			return nil, 0, association.Error
+		}
+		if err = db.Model(&articleUserModel).Offset(offset).Limit(limit).Related(&articleModels, "Articles").Error; err != nil {
+			return nil, 0, err
 		}
 	} else if favorited != "" {
-		var userModel models.User
-		tx.Where(models.User{Username: favorited}).First(&userModel)
-		articleUserModel := r.GetArticleUser(ctx, userModel.ID)
-		if articleUserModel.ID != 0 {
-			var favoriteModels []models.Favorite
-			tx.Where(models.Favorite{
-				FavoriteByID: articleUserModel.ID,
-			}).Offset(offset).Limit(limit).Find(&favoriteModels)
-
-			count = tx.Model(&articleUserModel).Association("Favorites").Count()
-			for _, favorite := range favoriteModels {
-				var model models.Article
-				tx.Model(&favorite).Related(&model, "Favorite")
-				articleModels = append(articleModels, model)
+		articleUserModel, found, err := r.findArticleUserByUsername(db, favorited)
+		if err != nil || !found {
+			return articleModels, 0, err
+		}
+		var favoriteModels []models.Favorite
+		err = db.Where(models.Favorite{
+			FavoriteByID: articleUserModel.ID,
+		}).Offset(offset).Limit(limit).Find(&favoriteModels).Error
+		if err != nil {
+			return nil, 0, err
+		}
+		association := db.Model(&articleUserModel).Association("Favorites")
+		if count = association.Count(); association.Error != nil {
+			return nil, 0, association.Error
+		}
+		for _, favorite := range favoriteModels {
+			var model models.Article
+			// deleted articles stay favorited until they're restored
+			if err = db.Model(&favorite).Related(&model, "Favorite").Error; postgres.IsRecordNotFoundError(err) {
+				continue
+			} else if err != nil {
+				return nil, 0, err
 			}
+			articleModels = append(articleModels, model)
 		}
 	} else {
-		db.Model(&articleModels).Count(&count)
-		db.Offset(offset).Limit(limit).Find(&articleModels)
+		if err := db.Model(&articleModels).Count(&count).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := db.Offset(offset).Limit(limit).Find(&articleModels).Error; err != nil {
+			return nil, 0, err
+		}
 	}
 
-	for i, _ := range articleModels {
-		tx.Model(&articleModels[i]).Related(&articleModels[i].Author, "Author")
-		tx.Model(&articleModels[i].Author).Related(&articleModels[i].Author.User)
-		tx.Model(&articleModels[i]).Related(&articleModels[i].Tags, "Tags")
+	for i := range articleModels {
+		if err := loadArticleRelations(db, &articleModels[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return articleModels, count, nil
+}
+
+// The article user of a username, found is false when no user has it
+func (r *articleRepo) findArticleUserByUsername(db *postgres.DB, username string) (models.ArticleUser, bool, error) {
+	var articleUserModel models.ArticleUser
+	err := db.Joins("JOIN user_models ON user_models.id = article_user_models.user_id").
+		Where("user_models.username = ?", username).First(&articleUserModel).Error
+	if postgres.IsRecordNotFoundError(err) {
+		return articleUserModel, false, nil
 	}
-	err := tx.Commit().Error
-	return articleModels, count, err
+	return articleUserModel, err == nil, err
 }
 
 func (r *articleRepo) GetArticleFeed(c context.Context, userId uint, limit, offset int) ([]models.Article, int, error) {
-	span, ctx := opentracing.StartSpanFromContext(c, "article.articleRepo.GetArticleFeed")
-	defer span.Finish()
+	ctx, span := tracing.Start(c, "article.articleRepo.GetArticleFeed")
+	defer span.End()
 	var articleModels []models.Article
 	var count int
 
-	tx := r.db.Begin()
+	db := postgres.WithContext(ctx, r.db)
 	userRepo := UserRepo.NewUserRepository(r.db)
-	followings := userRepo.GetFollowingsByUser(ctx, userId)
+	followings, err := userRepo.GetFollowingsByUser(ctx, userId)
+	if err != nil {
+		return nil, 0, err
+	}
 	var articleUserModels []uint
 	for _, following := range followings {
 		articleUserModel := r.GetArticleUser(ctx, following.ID)
 		articleUserModels = append(articleUserModels, articleUserModel.ID)
 	}
 
-	tx.Where("author_id in (?)", articleUserModels).Order("updated_at desc").Offset(offset).Limit(limit).Find(&articleModels)
-
-	for i, _ := range articleModels {
-		tx.Model(&articleModels[i]).Related(&articleModels[i].Author, "Author")
-		tx.Model(&articleModels[i].Author).Related(&articleModels[i].Author.User)
-		tx.Model(&articleModels[i]).Related(&articleModels[i].Tags, "Tags")
+	if err = db.Model(&models.Article{}).Where("author_id in (?)", articleUserModels).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	err = db.Where("author_id in (?)", articleUserModels).Order("updated_at desc").Offset(offset).Limit(limit).Find(&articleModels).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range articleModels {
+		if err = loadArticleRelations(db, &articleModels[i]); err != nil {
+			return nil, 0, err
+		}
 	}
-	err := tx.Commit().Error
-	return articleModels, count, err
+	return articleModels, count, nil
 }
 
 func (r *articleRepo) SaveOne(ctx context.Context, data interface{}) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "article.articleRepo.SaveOne")
-	defer span.Finish()
+	_, span := tracing.Start(ctx, "article.articleRepo.SaveOne")
+	defer span.End()
 
-	err := r.db.Save(data).Error
+	err := postgres.WithContext(ctx, r.db).Save(data).Error
 	return err
 }
 
-func (r *articleRepo) Update(c context.Context, data *models.Article) error {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.Update")
-	defer span.Finish()
-	err := r.db.Model(&models.Article{ID: data.ID}).Update(data).Error
-	return err
+// Update the article when it's still at data.Version and bump its version.
+// updated is false when another update came first.
+func (r *articleRepo) Update(c context.Context, data *models.Article) (bool, error) {
+	ctx, span := tracing.Start(c, "article.articleRepo.Update")
+	defer span.End()
+
+	updated := false
+	err := postgres.RunInTransaction(ctx, r.db, func(ctx context.Context) error {
+		db := postgres.WithContext(ctx, r.db)
+		// the row stays locked until commit, a concurrent update waits and then finds the new version
+		result := db.Model(&models.Article{}).Where("id = ? AND version = ?", data.ID, data.Version).
+			UpdateColumn("version", gorm.Expr("version + 1"))
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		return db.Model(&models.Article{ID: data.ID}).Omit("favorites_count", "version").Update(data).Error
+	})
+	if err != nil || !updated {
+		return false, err
+	}
+	data.Version++
+	return true, nil
 }
 
-func (r *articleRepo) DeleteArticleModel(c context.Context, condition interface{}) error {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.DeleteArticleModel")
-	defer span.Finish()
-	err := r.db.Unscoped().Where(condition).Delete(models.Article{}).Error
-	return err
+// Soft delete like SoftDeleteArticle, deleted is false when no article matches condition
+func (r *articleRepo) DeleteArticleModel(c context.Context, condition interface{}) (bool, error) {
+	c, span := tracing.Start(c, "article.articleRepo.DeleteArticleModel")
+	defer span.End()
+	result := postgres.WithContext(c, r.db).Where(condition).Delete(models.Article{})
+	return result.RowsAffected > 0, result.Error
 }
 
 func (r *articleRepo) UpsertTags(c context.Context, tags []string) ([]models.Tag, error) {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.UpsertTags")
-	defer span.Finish()
+	c, span := tracing.Start(c, "article.articleRepo.UpsertTags")
+	defer span.End()
 
+	db := postgres.WithContext(c, r.db)
 	var tagList []models.Tag
 	for _, tag := range tags {
-		var tagModel models.Tag
-		err := r.db.FirstOrCreate(&tagModel, models.Tag{Tag: tag}).Error
+		// a racing insert of the same tag would abort the transaction of the caller
+		err := db.Exec(`
+			INSERT INTO tag_models (tag, created_at, updated_at)
+			VALUES (?, now(), now())
+			ON CONFLICT (tag) DO NOTHING`, tag).Error
 		if err != nil {
 			return nil, err
 		}
+		var tagModel models.Tag
+		if err = db.Where(models.Tag{Tag: tag}).First(&tagModel).Error; err != nil {
+			return nil, err
+		}
 		tagList = append(tagList, tagModel)
 	}
 	return tagList, nil
 }
 
-func (r *articleRepo) SetFavorite(c context.Context, articleId, userId uint) error {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.SetFavorite")
-	defer span.Finish()
+// The favorite and the favorites count change together, in the transaction of c when there's one.
+// created is false when the user already favorited the article.
+func (r *articleRepo) SetFavorite(c context.Context, articleId, userId uint) (created bool, err error) {
+	ctx, span := tracing.Start(c, "article.articleRepo.SetFavorite")
+	defer span.End()
+
+	err = postgres.RunInTransaction(ctx, r.db, func(ctx context.Context) error {
+		db := postgres.WithContext(ctx, r.db)
+		// gorm's Create can't tell an existing favorite from a new one
+		result := db.Exec(`
+			INSERT INTO favorite_models (favorite_id, favorite_by_id, created_at, updated_at)
+			VALUES (?, ?, now(), now())
+			ON CONFLICT DO NOTHING`, articleId, userId)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		return r.addFavoritesCount(db, articleId, 1)
+	})
+	return created && err == nil, err
+}
 
-	var favorite models.Favorite
-	err := r.db.FirstOrCreate(&favorite, &models.Favorite{
-		FavoriteID:   articleId,
-		FavoriteByID: userId,
-	}).Error
-	return err
+// removed is false when the user hadn't favorited the article
+func (r *articleRepo) RemoveFavorite(c context.Context, articleId, userId uint) (removed bool, err error) {
+	ctx, span := tracing.Start(c, "article.articleRepo.RemoveFavorite")
+	defer span.End()
+
+	err = postgres.RunInTransaction(ctx, r.db, func(ctx context.Context) error {
+		db := postgres.WithContext(ctx, r.db)
+		result := db.Unscoped().Where(models.Favorite{
+			FavoriteID:   articleId,
+			FavoriteByID: userId,
+		}).Delete(&models.Favorite{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		removed = true
+		return r.addFavoritesCount(db, articleId, -int(result.RowsAffected))
+	})
+	return removed && err == nil, err
 }
 
-func (r *articleRepo) RemoveFavorite(c context.Context, articleId, userId uint) error {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.RemoveFavorite")
-	defer span.Finish()
+func (r *articleRepo) addFavoritesCount(db *postgres.DB, articleId uint, delta int) error {
+	return db.Unscoped().Model(&models.Article{ID: articleId}).
+		UpdateColumn("favorites_count", gorm.Expr("GREATEST(favorites_count + ?, 0)", delta)).Error
+}
 
-	err := r.db.Unscoped().Where(models.Favorite{
-		FavoriteID:   articleId,
-		FavoriteByID: userId,
-	}).Delete(&models.Favorite{}).Error
+// Set the favorites count of every article from its favorites, returns how many were off
+func (r *articleRepo) RecomputeFavoritesCounts(c context.Context) (int64, error) {
+	c, span := tracing.Start(c, "article.articleRepo.RecomputeFavoritesCounts")
+	defer span.End()
+
+	result := postgres.WithContext(c, r.db).Exec(`
+		UPDATE article_models a
+		SET favorites_count = counted.count
+		FROM (
+			SELECT a.id, count(f.id) AS count
+			FROM article_models a
+			LEFT JOIN favorite_models f ON f.favorite_id = a.id AND f.deleted_at IS NULL
+			GROUP BY a.id
+		) counted
+		WHERE counted.id = a.id AND a.favorites_count <> counted.count`)
+	return result.RowsAffected, result.Error
+}
+
+// Soft delete, the article can be brought back with RestoreArticle
+func (r *articleRepo) SoftDeleteArticle(c context.Context, articleId uint) error {
+	c, span := tracing.Start(c, "article.articleRepo.SoftDeleteArticle")
+	defer span.End()
+
+	err := postgres.WithContext(c, r.db).Delete(&models.Article{ID: articleId}).Error
 	return err
 }
 
+// Undo SoftDeleteArticle of the last deleted article with the slug, reports false when
+// there's none. Fails with a unique violation while another article has the slug.
+func (r *articleRepo) RestoreArticle(c context.Context, slug string) (bool, error) {
+	c, span := tracing.Start(c, "article.articleRepo.RestoreArticle")
+	defer span.End()
+
+	result := postgres.WithContext(c, r.db).Unscoped().Model(&models.Article{}).
+		Where(`id = (SELECT id FROM article_models WHERE slug = ? AND deleted_at IS NOT NULL
+			ORDER BY deleted_at DESC LIMIT 1)`, slug).
+		UpdateColumn("deleted_at", gorm.Expr("NULL"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *articleRepo) FindTags(c context.Context, tags []string) ([]models.Tag, error) {
+	c, span := tracing.Start(c, "article.articleRepo.FindTags")
+	defer span.End()
+
+	var tagModels []models.Tag
+	err := postgres.WithContext(c, r.db).Where("tag IN (?)", tags).Find(&tagModels).Error
+	return tagModels, err
+}
+
+// Move the articles of the source tags to the target and delete the source tags
+func (r *articleRepo) MergeTags(c context.Context, sourceIds []uint, targetId uint) error {
+	ctx, span := tracing.Start(c, "article.articleRepo.MergeTags")
+	defer span.End()
+
+	return postgres.RunInTransaction(ctx, r.db, func(ctx context.Context) error {
+		db := postgres.WithContext(ctx, r.db)
+		err := db.Exec(`
+			INSERT INTO article_tags (article_id, tag_id)
+			SELECT DISTINCT article_id, ? FROM article_tags WHERE tag_id IN (?)
+			ON CONFLICT DO NOTHING`, targetId, sourceIds).Error
+		if err != nil {
+			return err
+		}
+		if err = db.Exec("DELETE FROM article_tags WHERE tag_id IN (?)", sourceIds).Error; err != nil {
+			return err
+		}
+		return db.Unscoped().Where("id IN (?)", sourceIds).Delete(&models.Tag{}).Error
+	})
+}
+
+func (r *articleRepo) FindArticlesByAuthor(c context.Context, authorId uint) ([]models.Article, error) {
+	c, span := tracing.Start(c, "article.articleRepo.FindArticlesByAuthor")
+	defer span.End()
+
+	db := postgres.WithContext(c, r.db)
+	var articleModels []models.Article
+	if err := db.Where(&models.Article{AuthorID: authorId}).Order("id").Find(&articleModels).Error; err != nil {
+		return nil, err
+	}
+	for i := range articleModels {
+		if err := db.Model(&articleModels[i]).Related(&articleModels[i].Tags, "Tags").Error; err != nil {
+			return nil, err
+		}
+	}
+	return articleModels, nil
+}
+
+func (r *articleRepo) FindFavoritedArticles(c context.Context, articleUserId uint) ([]models.Article, error) {
+	c, span := tracing.Start(c, "article.articleRepo.FindFavoritedArticles")
+	defer span.End()
+
+	var articleModels []models.Article
+	err := postgres.WithContext(c, r.db).
+		Joins("JOIN favorite_models ON favorite_models.favorite_id = article_models.id AND favorite_models.deleted_at IS NULL").
+		Where("favorite_models.favorite_by_id = ?", articleUserId).
+		Order("favorite_models.id").
+		Find(&articleModels).Error
+	return articleModels, err
+}
+
+func (r *articleRepo) FindCommentsByAuthor(c context.Context, authorId uint) ([]models.Comment, error) {
+	c, span := tracing.Start(c, "article.articleRepo.FindCommentsByAuthor")
+	defer span.End()
+
+	var commentModels []models.Comment
+	err := postgres.WithContext(c, r.db).Where(&models.Comment{AuthorID: authorId}).Order("id").Find(&commentModels).Error
+	return commentModels, err
+}
+
 func (r *articleRepo) GetArticleComments(c context.Context, article models.Article) ([]models.Comment, error) {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.GetArticleComments")
-	defer span.Finish()
+	c, span := tracing.Start(c, "article.articleRepo.GetArticleComments")
+	defer span.End()
 
-	tx := r.db.Begin()
-	tx.Model(&article).Related(&article.Comments, "Comments")
-	for i, _ := range article.Comments {
-		tx.Model(&article.Comments[i]).Related(&article.Comments[i].Author, "Author")
-		tx.Model(&article.Comments[i].Author).Related(&article.Comments[i].Author.User)
+	db := postgres.WithContext(c, r.db)
+	if err := db.Model(&article).Related(&article.Comments, "Comments").Error; err != nil {
+		return nil, err
 	}
-	err := tx.Commit().Error
-	return article.Comments, err
+	for i := range article.Comments {
+		if err := db.Model(&article.Comments[i]).Related(&article.Comments[i].Author, "Author").Error; err != nil {
+			return nil, err
+		}
+		if err := db.Model(&article.Comments[i].Author).Related(&article.Comments[i].Author.User).Error; err != nil {
+			return nil, err
+		}
+	}
+	return article.Comments, nil
+}
+
+func (r *articleRepo) FindOneComment(c context.Context, condition interface{}) (models.Comment, error) {
+	c, span := tracing.Start(c, "article.articleRepo.FindOneComment")
+	defer span.End()
+
+	var model models.Comment
+	err := postgres.WithContext(c, r.db).Where(condition).First(&model).Error
+	return model, err
 }
 
 func (r *articleRepo) DeleteComment(c context.Context, condition interface{}) error {
-	span, _ := opentracing.StartSpanFromContext(c, "article.articleRepo.DeleteComment")
-	defer span.Finish()
+	c, span := tracing.Start(c, "article.articleRepo.DeleteComment")
+	defer span.End()
 
-	err := r.db.Unscoped().Where(condition).Delete(models.Comment{}).Error
+	err := postgres.WithContext(c, r.db).Unscoped().Where(condition).Delete(models.Comment{}).Error
 	return err
 }
 
-func (r *articleRepo) GetTags() ([]models.Tag, error) {
+func (r *articleRepo) GetTags(c context.Context) ([]models.Tag, error) {
+	c, span := tracing.Start(c, "article.articleRepo.GetTags")
+	defer span.End()
+
 	var models []models.Tag
-	err := r.db.Find(&models).Error
+	err := postgres.WithContext(c, r.db).Find(&models).Error
 	return models, err
 }
@@ -0,0 +1,760 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	article "github.com/gothinkster/golang-gin-realworld-example-app/internal/article"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// Article repository of a single process, for running without postgres. Users
+// and follows come from userRepo like the gorm repository reads them from the
+// user tables. Soft deleted rows are skipped unless the gorm query is Unscoped,
+// missing records are gorm.ErrRecordNotFound and unique indexes fail with the
+// unique violation of postgres.
+type articleMemoryRepo struct {
+	mu           sync.RWMutex
+	userRepo     user.Repository
+	lastID       uint
+	articles     map[uint]models.Article
+	articleTags  map[uint][]uint
+	articleUsers map[uint]models.ArticleUser
+	tags         map[uint]models.Tag
+	favorites    []models.Favorite
+	comments     map[uint]models.Comment
+}
+
+func NewArticleMemoryRepository(userRepo user.Repository) article.Repository {
+	return &articleMemoryRepo{
+		userRepo:     userRepo,
+		articles:     make(map[uint]models.Article),
+		articleTags:  make(map[uint][]uint),
+		articleUsers: make(map[uint]models.ArticleUser),
+		tags:         make(map[uint]models.Tag),
+		comments:     make(map[uint]models.Comment),
+	}
+}
+
+// ids are shared by all tables, which is fine as long as they are unique per table
+func (r *articleMemoryRepo) nextID() uint {
+	r.lastID++
+	return r.lastID
+}
+
+func uniqueViolation(constraint string) error {
+	return &pq.Error{Code: "23505", Message: fmt.Sprintf("duplicate key value violates unique constraint %q", constraint), Constraint: constraint}
+}
+
+func sortedIDs[T any](rows map[uint]T) []uint {
+	ids := make([]uint, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Rows of a page, a negative limit is no limit like in gorm
+func paginate[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return nil
+	}
+	if offset > 0 {
+		rows = rows[offset:]
+	}
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+func articleCondition(condition interface{}) (models.Article, error) {
+	switch c := condition.(type) {
+	case *models.Article:
+		return *c, nil
+	case models.Article:
+		return c, nil
+	}
+	return models.Article{}, fmt.Errorf("articleMemoryRepo: unsupported condition %T", condition)
+}
+
+// Non zero fields of condition have to match, like gorm's struct conditions
+func matchArticle(a, condition models.Article) bool {
+	return (condition.ID == 0 || a.ID == condition.ID) &&
+		(condition.Slug == "" || a.Slug == condition.Slug) &&
+		(condition.AuthorID == 0 || a.AuthorID == condition.AuthorID) &&
+		(condition.Version == 0 || a.Version == condition.Version)
+}
+
+func commentCondition(condition interface{}) (func(models.Comment) bool, error) {
+	switch c := condition.(type) {
+	case *models.Comment:
+		return func(comment models.Comment) bool {
+			return (c.ID == 0 || comment.ID == c.ID) &&
+				(c.ArticleID == 0 || comment.ArticleID == c.ArticleID) &&
+				(c.AuthorID == 0 || comment.AuthorID == c.AuthorID)
+		}, nil
+	case []uint:
+		// primary keys
+		return func(comment models.Comment) bool {
+			for _, id := range c {
+				if comment.ID == id {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, fmt.Errorf("articleMemoryRepo: unsupported condition %T", condition)
+}
+
+func (r *articleMemoryRepo) GetArticleUser(c context.Context, userID uint) models.ArticleUser {
+	var articleUserModel models.ArticleUser
+	if userID == 0 {
+		return articleUserModel
+	}
+	userModel, _ := r.userRepo.FindOneUser(c, &models.User{ID: userID})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	articleUserModel = r.findOrCreateArticleUser(userID)
+	articleUserModel.User = userModel
+	return articleUserModel
+}
+
+func (r *articleMemoryRepo) findOrCreateArticleUser(userID uint) models.ArticleUser {
+	for _, id := range sortedIDs(r.articleUsers) {
+		if r.articleUsers[id].UserID == userID && r.articleUsers[id].DeletedAt == nil {
+			return r.articleUsers[id]
+		}
+	}
+	now := time.Now()
+	articleUserModel := models.ArticleUser{ID: r.nextID(), UserID: userID, CreatedAt: now, UpdatedAt: now}
+	r.articleUsers[articleUserModel.ID] = articleUserModel
+	return articleUserModel
+}
+
+// The article user of a username, found is false when no user has it
+func (r *articleMemoryRepo) findArticleUserByUsername(c context.Context, username string) (models.ArticleUser, bool, error) {
+	userModel, err := r.userRepo.FindOneUser(c, &models.User{Username: username})
+	if gorm.IsRecordNotFoundError(err) {
+		return models.ArticleUser{}, false, nil
+	} else if err != nil {
+		return models.ArticleUser{}, false, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, id := range sortedIDs(r.articleUsers) {
+		if r.articleUsers[id].UserID == userModel.ID && r.articleUsers[id].DeletedAt == nil {
+			return r.articleUsers[id], true, nil
+		}
+	}
+	return models.ArticleUser{}, false, nil
+}
+
+// Load the author, their user and the tags of an article
+func (r *articleMemoryRepo) loadArticleRelations(c context.Context, model *models.Article) error {
+	r.mu.RLock()
+	author, ok := r.articleUsers[model.AuthorID]
+	model.Tags = r.tagsOf(model.ID)
+	r.mu.RUnlock()
+	if !ok || author.DeletedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	userModel, err := r.userRepo.FindOneUser(c, &models.User{ID: author.UserID})
+	if err != nil {
+		return err
+	}
+	author.User = userModel
+	model.Author = author
+	return nil
+}
+
+func (r *articleMemoryRepo) loadArticlesRelations(c context.Context, articleModels []models.Article) error {
+	for i := range articleModels {
+		if err := r.loadArticleRelations(c, &articleModels[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *articleMemoryRepo) tagsOf(articleId uint) []models.Tag {
+	var tagModels []models.Tag
+	for _, tagId := range r.articleTags[articleId] {
+		if tagModel, ok := r.tags[tagId]; ok {
+			tagModels = append(tagModels, tagModel)
+		}
+	}
+	return tagModels
+}
+
+func (r *articleMemoryRepo) hasTag(articleId, tagId uint) bool {
+	for _, id := range r.articleTags[articleId] {
+		if id == tagId {
+			return true
+		}
+	}
+	return false
+}
+
+// Like gorm saving a many to many association, links are added and never removed
+func (r *articleMemoryRepo) addTags(articleId uint, tagModels []models.Tag) {
+	for _, tagModel := range tagModels {
+		if !r.hasTag(articleId, tagModel.ID) {
+			r.articleTags[articleId] = append(r.articleTags[articleId], tagModel.ID)
+		}
+	}
+}
+
+// Articles which aren't soft deleted and match keep, in id order
+func (r *articleMemoryRepo) findArticles(keep func(models.Article) bool) []models.Article {
+	var articleModels []models.Article
+	for _, id := range sortedIDs(r.articles) {
+		if a := r.articles[id]; a.DeletedAt == nil && keep(a) {
+			articleModels = append(articleModels, a)
+		}
+	}
+	return articleModels
+}
+
+func (r *articleMemoryRepo) FindOneArticle(c context.Context, condition interface{}) (models.Article, error) {
+	cond, err := articleCondition(condition)
+	if err != nil {
+		return models.Article{}, err
+	}
+	r.mu.RLock()
+	found := r.findArticles(func(a models.Article) bool { return matchArticle(a, cond) })
+	r.mu.RUnlock()
+	if len(found) == 0 {
+		return models.Article{}, gorm.ErrRecordNotFound
+	}
+	model := found[0]
+	err = r.loadArticleRelations(c, &model)
+	return model, err
+}
+
+func (r *articleMemoryRepo) ArticleFavoritesCount(c context.Context, articleId uint) uint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count uint
+	for _, favorite := range r.favorites {
+		if favorite.FavoriteID == articleId && favorite.DeletedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *articleMemoryRepo) IsArticleFavoriteBy(c context.Context, userId uint, articleId uint) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.favoriteIndex(articleId, userId) >= 0
+}
+
+func (r *articleMemoryRepo) favoriteIndex(articleId, userId uint) int {
+	for i, favorite := range r.favorites {
+		if favorite.FavoriteID == articleId && favorite.FavoriteByID == userId && favorite.DeletedAt == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *articleMemoryRepo) FindManyArticle(c context.Context, tag, author, favorited string, limit, offset int) ([]models.Article, int, error) {
+	var articleModels []models.Article
+	var count int
+
+	if tag != "" {
+		r.mu.RLock()
+		var tagId uint
+		for _, id := range sortedIDs(r.tags) {
+			if r.tags[id].Tag == tag {
+				tagId = id
+				break
+			}
+		}
+		if tagId == 0 {
+			r.mu.RUnlock()
+			return articleModels, 0, nil
+		}
+		tagged := r.findArticles(func(a models.Article) bool { return r.hasTag(a.ID, tagId) })
+		r.mu.RUnlock()
+		count = len(tagged)
+		articleModels = paginate(tagged, limit, offset)
+	} else if author != "" {
+		articleUserModel, found, err := r.findArticleUserByUsername(c, author)
+		if err != nil || !found {
+			return articleModels, 0, err
+		}
+		r.mu.RLock()
+		authored := r.findArticles(func(a models.Article) bool { return a.AuthorID == articleUserModel.ID })
+		r.mu.RUnlock()
+		count = len(authored)
+		articleModels = paginate(authored, limit, offset)
+	} else if favorited != "" {
+		articleUserModel, found, err := r.findArticleUserByUsername(c, favorited)
+		if err != nil || !found {
+			return articleModels, 0, err
+		}
+		r.mu.RLock()
+		var favoriteModels []models.Favorite
+		for _, favorite := range r.favorites {
+			if favorite.FavoriteByID == articleUserModel.ID && favorite.DeletedAt == nil {
+				favoriteModels = append(favoriteModels, favorite)
+			}
+		}
+		count = len(favoriteModels)
+		for _, favorite := range paginate(favoriteModels, limit, offset) {
+			// deleted articles stay favorited until they're restored
+			if a, ok := r.articles[favorite.FavoriteID]; ok && a.DeletedAt == nil {
+				articleModels = append(articleModels, a)
+			}
+		}
+		r.mu.RUnlock()
+	} else {
+		r.mu.RLock()
+		all := r.findArticles(func(models.Article) bool { return true })
+		r.mu.RUnlock()
+		count = len(all)
+		articleModels = paginate(all, limit, offset)
+	}
+
+	if err := r.loadArticlesRelations(c, articleModels); err != nil {
+		return nil, 0, err
+	}
+	return articleModels, count, nil
+}
+
+func (r *articleMemoryRepo) GetArticleFeed(c context.Context, userId uint, limit, offset int) ([]models.Article, int, error) {
+	followings, err := r.userRepo.GetFollowingsByUser(c, userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	authors := make(map[uint]bool)
+	for _, following := range followings {
+		authors[r.GetArticleUser(c, following.ID).ID] = true
+	}
+
+	r.mu.RLock()
+	feed := r.findArticles(func(a models.Article) bool { return authors[a.AuthorID] })
+	r.mu.RUnlock()
+	sort.SliceStable(feed, func(i, j int) bool { return feed[i].UpdatedAt.After(feed[j].UpdatedAt) })
+
+	articleModels := paginate(feed, limit, offset)
+	if err = r.loadArticlesRelations(c, articleModels); err != nil {
+		return nil, 0, err
+	}
+	return articleModels, len(feed), nil
+}
+
+// Saves articles and comments. Like gorm the foreign keys are taken from the loaded
+// associations, and tags are linked to the article.
+func (r *articleMemoryRepo) SaveOne(ctx context.Context, data interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	switch model := data.(type) {
+	case *models.Article:
+		if model.Author.ID != 0 {
+			model.AuthorID = model.Author.ID
+		}
+		for id, a := range r.articles {
+			if id != model.ID && a.DeletedAt == nil && a.Slug == model.Slug {
+				return uniqueViolation("uix_article_models_slug")
+			}
+		}
+		if model.ID == 0 {
+			model.ID = r.nextID()
+			model.CreatedAt = now
+		}
+		if model.Version == 0 {
+			model.Version = 1
+		}
+		model.UpdatedAt = now
+		r.addTags(model.ID, model.Tags)
+		r.articles[model.ID] = stripArticle(*model)
+	case *models.Comment:
+		if model.Article.ID != 0 {
+			model.ArticleID = model.Article.ID
+		}
+		if model.Author.ID != 0 {
+			model.AuthorID = model.Author.ID
+		}
+		if model.ID == 0 {
+			model.ID = r.nextID()
+			model.CreatedAt = now
+		}
+		model.UpdatedAt = now
+		comment := *model
+		comment.Article, comment.Author = models.Article{}, models.ArticleUser{}
+		r.comments[model.ID] = comment
+	default:
+		return fmt.Errorf("articleMemoryRepo: can't save %T", data)
+	}
+	return nil
+}
+
+// Relations live in their own maps
+func stripArticle(a models.Article) models.Article {
+	a.Author, a.Tags, a.Comments = models.ArticleUser{}, nil, nil
+	return a
+}
+
+// Update the article when it's still at data.Version and bump its version.
+// updated is false when another update came first.
+func (r *articleMemoryRepo) Update(c context.Context, data *models.Article) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.articles[data.ID]
+	if !ok || stored.DeletedAt != nil || stored.Version != data.Version {
+		return false, nil
+	}
+	if data.Slug != "" {
+		for id, a := range r.articles {
+			if id != data.ID && a.DeletedAt == nil && a.Slug == data.Slug {
+				return false, uniqueViolation("uix_article_models_slug")
+			}
+		}
+		stored.Slug = data.Slug
+	}
+	if data.Title != "" {
+		stored.Title = data.Title
+	}
+	if data.Description != "" {
+		stored.Description = data.Description
+	}
+	if data.Body != "" {
+		stored.Body = data.Body
+	}
+	if data.Author.ID != 0 {
+		stored.AuthorID = data.Author.ID
+	} else if data.AuthorID != 0 {
+		stored.AuthorID = data.AuthorID
+	}
+	stored.Version++
+	stored.UpdatedAt = time.Now()
+	r.addTags(data.ID, data.Tags)
+	r.articles[data.ID] = stored
+	data.Version++
+	return true, nil
+}
+
+// Soft delete like SoftDeleteArticle, deleted is false when no article matches condition
+func (r *articleMemoryRepo) DeleteArticleModel(c context.Context, condition interface{}) (bool, error) {
+	cond, err := articleCondition(condition)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := false
+	now := time.Now()
+	for id, a := range r.articles {
+		if a.DeletedAt == nil && matchArticle(a, cond) {
+			a.DeletedAt = &now
+			r.articles[id] = a
+			deleted = true
+		}
+	}
+	return deleted, nil
+}
+
+func (r *articleMemoryRepo) UpsertTags(c context.Context, tags []string) ([]models.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var tagList []models.Tag
+	for _, tag := range tags {
+		tagList = append(tagList, r.upsertTag(tag))
+	}
+	return tagList, nil
+}
+
+func (r *articleMemoryRepo) upsertTag(tag string) models.Tag {
+	for _, tagModel := range r.tags {
+		if tagModel.Tag == tag {
+			return tagModel
+		}
+	}
+	now := time.Now()
+	tagModel := models.Tag{ID: r.nextID(), Tag: tag, CreatedAt: now, UpdatedAt: now}
+	r.tags[tagModel.ID] = tagModel
+	return tagModel
+}
+
+// The favorite and the favorites count change together, created is false when the
+// user already favorited the article
+func (r *articleMemoryRepo) SetFavorite(c context.Context, articleId, userId uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.favoriteIndex(articleId, userId) >= 0 {
+		return false, nil
+	}
+	now := time.Now()
+	r.favorites = append(r.favorites, models.Favorite{ID: r.nextID(), FavoriteID: articleId, FavoriteByID: userId, CreatedAt: now, UpdatedAt: now})
+	r.addFavoritesCount(articleId, 1)
+	return true, nil
+}
+
+// removed is false when the user hadn't favorited the article
+func (r *articleMemoryRepo) RemoveFavorite(c context.Context, articleId, userId uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.favoriteIndex(articleId, userId)
+	if i < 0 {
+		return false, nil
+	}
+	r.favorites = append(r.favorites[:i], r.favorites[i+1:]...)
+	r.addFavoritesCount(articleId, -1)
+	return true, nil
+}
+
+func (r *articleMemoryRepo) addFavoritesCount(articleId uint, delta int) {
+	a, ok := r.articles[articleId]
+	if !ok {
+		return
+	}
+	count := int(a.FavoritesCount) + delta
+	if count < 0 {
+		count = 0
+	}
+	a.FavoritesCount = uint(count)
+	r.articles[articleId] = a
+}
+
+// Set the favorites count of every article from its favorites, returns how many were off
+func (r *articleMemoryRepo) RecomputeFavoritesCounts(c context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[uint]uint)
+	for _, favorite := range r.favorites {
+		if favorite.DeletedAt == nil {
+			counts[favorite.FavoriteID]++
+		}
+	}
+	var changed int64
+	for id, a := range r.articles {
+		if a.FavoritesCount != counts[id] {
+			a.FavoritesCount = counts[id]
+			r.articles[id] = a
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// Soft delete, the article can be brought back with RestoreArticle
+func (r *articleMemoryRepo) SoftDeleteArticle(c context.Context, articleId uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if a, ok := r.articles[articleId]; ok && a.DeletedAt == nil {
+		now := time.Now()
+		a.DeletedAt = &now
+		r.articles[articleId] = a
+	}
+	return nil
+}
+
+// Undo SoftDeleteArticle of the last deleted article with the slug, reports false when
+// there's none. Fails with a unique violation while another article has the slug.
+func (r *articleMemoryRepo) RestoreArticle(c context.Context, slug string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *models.Article
+	taken := false
+	for _, a := range r.articles {
+		switch {
+		case a.Slug != slug:
+		case a.DeletedAt == nil:
+			taken = true
+		case last == nil || a.DeletedAt.After(*last.DeletedAt):
+			a := a
+			last = &a
+		}
+	}
+	if last == nil {
+		return false, nil
+	}
+	if taken {
+		return false, uniqueViolation("uix_article_models_slug")
+	}
+	last.DeletedAt = nil
+	r.articles[last.ID] = *last
+	return true, nil
+}
+
+func (r *articleMemoryRepo) FindTags(c context.Context, tags []string) ([]models.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tagModels []models.Tag
+	for _, id := range sortedIDs(r.tags) {
+		for _, tag := range tags {
+			if r.tags[id].Tag == tag {
+				tagModels = append(tagModels, r.tags[id])
+				break
+			}
+		}
+	}
+	return tagModels, nil
+}
+
+// Move the articles of the source tags to the target and delete the source tags
+func (r *articleMemoryRepo) MergeTags(c context.Context, sourceIds []uint, targetId uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	isSource := make(map[uint]bool)
+	for _, id := range sourceIds {
+		isSource[id] = true
+	}
+	for articleId, tagIds := range r.articleTags {
+		kept := make([]uint, 0, len(tagIds))
+		merged := false
+		for _, tagId := range tagIds {
+			if isSource[tagId] {
+				merged = true
+			} else {
+				kept = append(kept, tagId)
+			}
+		}
+		r.articleTags[articleId] = kept
+		if merged && !r.hasTag(articleId, targetId) {
+			r.articleTags[articleId] = append(r.articleTags[articleId], targetId)
+		}
+	}
+	for _, id := range sourceIds {
+		delete(r.tags, id)
+	}
+	return nil
+}
+
+func (r *articleMemoryRepo) FindArticlesByAuthor(c context.Context, authorId uint) ([]models.Article, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	articleModels := r.findArticles(func(a models.Article) bool { return a.AuthorID == authorId })
+	for i := range articleModels {
+		articleModels[i].Tags = r.tagsOf(articleModels[i].ID)
+	}
+	return articleModels, nil
+}
+
+func (r *articleMemoryRepo) FindFavoritedArticles(c context.Context, articleUserId uint) ([]models.Article, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var articleModels []models.Article
+	for _, favorite := range r.favorites {
+		if favorite.FavoriteByID != articleUserId || favorite.DeletedAt != nil {
+			continue
+		}
+		if a, ok := r.articles[favorite.FavoriteID]; ok && a.DeletedAt == nil {
+			articleModels = append(articleModels, a)
+		}
+	}
+	return articleModels, nil
+}
+
+// Comments which aren't soft deleted and match keep, in id order
+func (r *articleMemoryRepo) findComments(keep func(models.Comment) bool) []models.Comment {
+	var commentModels []models.Comment
+	for _, id := range sortedIDs(r.comments) {
+		if comment := r.comments[id]; comment.DeletedAt == nil && keep(comment) {
+			commentModels = append(commentModels, comment)
+		}
+	}
+	return commentModels
+}
+
+func (r *articleMemoryRepo) FindCommentsByAuthor(c context.Context, authorId uint) ([]models.Comment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.findComments(func(comment models.Comment) bool { return comment.AuthorID == authorId }), nil
+}
+
+func (r *articleMemoryRepo) GetArticleComments(c context.Context, article models.Article) ([]models.Comment, error) {
+	r.mu.RLock()
+	comments := r.findComments(func(comment models.Comment) bool { return comment.ArticleID == article.ID })
+	authors := make([]models.ArticleUser, len(comments))
+	for i, comment := range comments {
+		author, ok := r.articleUsers[comment.AuthorID]
+		if !ok {
+			r.mu.RUnlock()
+			return nil, gorm.ErrRecordNotFound
+		}
+		authors[i] = author
+	}
+	r.mu.RUnlock()
+
+	for i := range comments {
+		userModel, err := r.userRepo.FindOneUser(c, &models.User{ID: authors[i].UserID})
+		if err != nil {
+			return nil, err
+		}
+		authors[i].User = userModel
+		comments[i].Author = authors[i]
+	}
+	return comments, nil
+}
+
+func (r *articleMemoryRepo) FindOneComment(c context.Context, condition interface{}) (models.Comment, error) {
+	match, err := commentCondition(condition)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := r.findComments(match)
+	if len(found) == 0 {
+		return models.Comment{}, gorm.ErrRecordNotFound
+	}
+	return found[0], nil
+}
+
+func (r *articleMemoryRepo) DeleteComment(c context.Context, condition interface{}) error {
+	match, err := commentCondition(condition)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, comment := range r.comments {
+		if match(comment) {
+			delete(r.comments, id)
+		}
+	}
+	return nil
+}
+
+func (r *articleMemoryRepo) GetTags(c context.Context) ([]models.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tagModels []models.Tag
+	for _, id := range sortedIDs(r.tags) {
+		tagModels = append(tagModels, r.tags[id])
+	}
+	return tagModels, nil
+}
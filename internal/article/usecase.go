@@ -2,11 +2,33 @@ package article
 
 import (
 	"context"
+	"errors"
 
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
 )
 
+var (
+	ErrArticleNotFound   = apperrors.NotFound("article", errors.New("Invalid slug"))
+	ErrCommentNotFound   = apperrors.NotFound("comment", errors.New("Invalid id"))
+	ErrSlugTaken         = apperrors.Conflict("article", errors.New("An article with this title already exists"))
+	ErrNotArticleAuthor  = apperrors.Forbidden("article", errors.New("Only the author can change the article"))
+	ErrNotCommentAuthor  = apperrors.Forbidden("comment", errors.New("Only the author can delete the comment"))
+	ErrInvalidPagination = apperrors.Validation("page", errors.New("Invalid param"))
+	ErrTagNotFound       = apperrors.NotFound("tag", errors.New("None of the tags exist"))
+	ErrInvalidTagMerge   = apperrors.Validation("tag", errors.New("Can't merge a tag into itself"))
+	ErrVersionMismatch   = apperrors.PreconditionFailed("article", errors.New("The article was changed since it was read"))
+	ErrVersionRequired   = apperrors.PreconditionRequired("article", errors.New("If-Match with the article's ETag is required"))
+)
+
+// Everything a user wrote or favorited, for data exports
+type UserContent struct {
+	Articles  []models.Article
+	Comments  []models.Comment
+	Favorites []models.Article
+}
+
 type UseCase interface {
 	GetArticleUser(ctx context.Context, userID uint) models.ArticleUser
 	GetArticles(ctx context.Context, tag, author, favorited string, pagination *utils.PaginationQuery) ([]models.Article, int, error)
@@ -14,10 +36,15 @@ type UseCase interface {
 	GetArticle(ctx context.Context, slug string) (models.Article, error)
 	CreateArticle(ctx context.Context, articleModel *models.Article, tags []string) (*models.Article, error)
 	UpdateArticle(ctx context.Context, slug string, articleModel *models.Article, tags []string) (*models.Article, error)
-	DeleteArticle(ctx context.Context, slug string) error
+	DeleteArticle(ctx context.Context, slug string, userID, version uint) error
 	CreateFavorite(ctx context.Context, slug string, userID uint) (*models.Article, error)
 	DeleteFavorite(ctx context.Context, slug string, userID uint) (*models.Article, error)
 	GetCommentsByArticle(ctx context.Context, slug string) ([]models.Comment, error)
 	CreateComment(ctx context.Context, slug string, userID uint, comment *models.Comment) (*models.Comment, error)
-	DeleteComment(ctx context.Context, userID uint, commentIDs []uint) error
+	DeleteComment(ctx context.Context, userID uint, commentID uint) error
+	RemoveArticle(ctx context.Context, slug string) error
+	RestoreArticle(ctx context.Context, slug string) (models.Article, error)
+	MergeTags(ctx context.Context, sources []string, target string) (int, error)
+	RecomputeFavoritesCounts(ctx context.Context) (int64, error)
+	GetUserContent(ctx context.Context, userID uint) (*UserContent, error)
 }
@@ -2,12 +2,12 @@ package usecase
 
 import (
 	"context"
-	"errors"
 
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/article"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
-	"github.com/opentracing/opentracing-go"
 )
 
 // Comments UseCase
@@ -15,11 +15,12 @@ type articleUC struct {
 	// cfg      *config.Config
 	// logger   logger.Logger
 	articleRepo article.Repository
+	txManager   postgres.TxManager
 }
 
 // Comments UseCase constructor
-func NewArticleUseCase(articleRepo article.Repository) article.UseCase {
-	return &articleUC{articleRepo: articleRepo}
+func NewArticleUseCase(articleRepo article.Repository, txManager postgres.TxManager) article.UseCase {
+	return &articleUC{articleRepo: articleRepo, txManager: txManager}
 }
 
 func (uc *articleUC) GetArticleUser(ctx context.Context, userID uint) models.ArticleUser {
@@ -27,140 +28,335 @@ func (uc *articleUC) GetArticleUser(ctx context.Context, userID uint) models.Art
 }
 
 func (uc *articleUC) GetArticles(ctx context.Context, tag, author, favorited string, pagination *utils.PaginationQuery) ([]models.Article, int, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.GetArticles")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "article.usecase.GetArticles")
+	defer span.End()
 	return uc.articleRepo.FindManyArticle(ctx, tag, author, favorited, pagination.Limit, pagination.Offset)
 }
 
 func (uc *articleUC) GetFeeds(ctx context.Context, user models.User, pagination *utils.PaginationQuery) ([]models.Article, int, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.GetFeeds")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "article.usecase.GetFeeds")
+	defer span.End()
 
 	articleUserModel := uc.articleRepo.GetArticleUser(ctx, user.ID)
 	return uc.articleRepo.GetArticleFeed(ctx, articleUserModel.UserID, pagination.Limit, pagination.Offset)
 }
 
 func (uc *articleUC) GetArticle(ctx context.Context, slug string) (models.Article, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.GetArticle")
-	defer span.Finish()
-	return uc.articleRepo.FindOneArticle(ctx, &models.Article{Slug: slug})
+	ctx, span := tracing.Start(ctx, "article.usecase.GetArticle")
+	defer span.End()
+	return uc.findArticle(ctx, slug)
 }
 
-func (uc *articleUC) CreateArticle(ctx context.Context, articleModel *models.Article, tags []string) (*models.Article, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.CreateArticle")
-	defer span.Finish()
-	articleModel.Author = uc.articleRepo.GetArticleUser(ctx, articleModel.AuthorID)
-	tagModels, _ := uc.articleRepo.UpsertTags(ctx, tags)
-	articleModel.Tags = tagModels
-
-	err := uc.articleRepo.SaveOne(ctx, &articleModel)
+// Find an article by slug, a missing one is ErrArticleNotFound
+func (uc *articleUC) findArticle(ctx context.Context, slug string) (models.Article, error) {
+	articleModel, err := uc.articleRepo.FindOneArticle(ctx, &models.Article{Slug: slug})
+	if postgres.IsRecordNotFoundError(err) {
+		return articleModel, article.ErrArticleNotFound
+	}
 	return articleModel, err
 }
 
-func (uc *articleUC) UpdateArticle(ctx context.Context, slug string, updateArticle *models.Article, tags []string) (*models.Article, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.UpdateArticle")
-	defer span.Finish()
-
-	articleModel, err := uc.articleRepo.FindOneArticle(ctx, &models.Article{Slug: slug})
+// Find an article by slug that userID is the author of
+func (uc *articleUC) findOwnArticle(ctx context.Context, slug string, userID uint) (models.Article, error) {
+	articleModel, err := uc.findArticle(ctx, slug)
 	if err != nil {
-		return nil, err
-	}
-	if updateArticle.Title != "" {
-		articleModel.Title = updateArticle.Title
-	}
-	if updateArticle.Description != "" {
-		articleModel.Description = updateArticle.Description
+		return articleModel, err
 	}
-	if updateArticle.Body != "" {
-		articleModel.Body = updateArticle.Body
+	if articleModel.AuthorID != uc.articleRepo.GetArticleUser(ctx, userID).ID {
+		return articleModel, article.ErrNotArticleAuthor
 	}
+	return articleModel, nil
+}
 
-	articleModel.Author = uc.articleRepo.GetArticleUser(ctx, articleModel.AuthorID)
-	tagModels, _ := uc.articleRepo.UpsertTags(ctx, tags)
-	articleModel.Tags = tagModels
+// The article and its tags are saved together, a failure leaves no orphan tags behind
+func (uc *articleUC) CreateArticle(ctx context.Context, articleModel *models.Article, tags []string) (*models.Article, error) {
+	ctx, span := tracing.Start(ctx, "article.usecase.CreateArticle")
+	defer span.End()
+
+	err := uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		articleModel.Author = uc.articleRepo.GetArticleUser(ctx, articleModel.AuthorID)
+		tagModels, err := uc.articleRepo.UpsertTags(ctx, tags)
+		if err != nil {
+			return err
+		}
+		articleModel.Tags = tagModels
+		return uc.articleRepo.SaveOne(ctx, articleModel)
+	})
+	if postgres.IsUniqueViolation(err) {
+		return nil, article.ErrSlugTaken
+	} else if err != nil {
+		return nil, err
+	}
+	articlesPublishedTotal.Inc()
+	return articleModel, nil
+}
 
-	err = uc.articleRepo.Update(ctx, &articleModel)
-	return &articleModel, err
+// Apply the non empty fields of updateArticle. When its Version isn't 0 the article has to
+// still be at that version, edits based on an outdated read are ErrVersionMismatch.
+func (uc *articleUC) UpdateArticle(ctx context.Context, slug string, updateArticle *models.Article, tags []string) (*models.Article, error) {
+	ctx, span := tracing.Start(ctx, "article.usecase.UpdateArticle")
+	defer span.End()
+
+	var articleModel models.Article
+	err := uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if articleModel, err = uc.findOwnArticle(ctx, slug, updateArticle.AuthorID); err != nil {
+			return err
+		}
+		if updateArticle.Version != 0 && updateArticle.Version != articleModel.Version {
+			return article.ErrVersionMismatch
+		}
+		if updateArticle.Title != "" {
+			articleModel.Title = updateArticle.Title
+		}
+		if updateArticle.Description != "" {
+			articleModel.Description = updateArticle.Description
+		}
+		if updateArticle.Body != "" {
+			articleModel.Body = updateArticle.Body
+		}
+
+		articleModel.Author = uc.articleRepo.GetArticleUser(ctx, updateArticle.AuthorID)
+		tagModels, err := uc.articleRepo.UpsertTags(ctx, tags)
+		if err != nil {
+			return err
+		}
+		articleModel.Tags = tagModels
+		updated, err := uc.articleRepo.Update(ctx, &articleModel)
+		if err == nil && !updated {
+			return article.ErrVersionMismatch
+		}
+		return err
+	})
+	if postgres.IsUniqueViolation(err) {
+		return nil, article.ErrSlugTaken
+	} else if err != nil {
+		return nil, err
+	}
+	return &articleModel, nil
 }
 
-func (uc *articleUC) DeleteArticle(ctx context.Context, slug string) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.DeleteArticle")
-	defer span.Finish()
+// Delete an article of userID, a version other than 0 has to be the current one
+func (uc *articleUC) DeleteArticle(ctx context.Context, slug string, userID, version uint) error {
+	ctx, span := tracing.Start(ctx, "article.usecase.DeleteArticle")
+	defer span.End()
 
-	_, err := uc.articleRepo.FindOneArticle(ctx, &models.Article{Slug: slug})
+	articleModel, err := uc.findOwnArticle(ctx, slug, userID)
 	if err != nil {
 		return err
 	}
+	if version != 0 && version != articleModel.Version {
+		return article.ErrVersionMismatch
+	}
 
-	return uc.articleRepo.DeleteArticleModel(ctx, &models.Article{Slug: slug})
+	// an update between the read and the delete changed the version
+	deleted, err := uc.articleRepo.DeleteArticleModel(ctx, &models.Article{ID: articleModel.ID, Version: articleModel.Version})
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return article.ErrVersionMismatch
+	}
+	return nil
 }
 
+// The favorite and the favorites count of the article change together
 func (uc *articleUC) CreateFavorite(ctx context.Context, slug string, userID uint) (*models.Article, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.CreateFavorite")
-	defer span.Finish()
-
-	articleModel, err := uc.articleRepo.FindOneArticle(ctx, &models.Article{Slug: slug})
+	ctx, span := tracing.Start(ctx, "article.usecase.CreateFavorite")
+	defer span.End()
+
+	var articleModel models.Article
+	var created bool
+	err := uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if articleModel, err = uc.findArticle(ctx, slug); err != nil {
+			return err
+		}
+		if created, err = uc.articleRepo.SetFavorite(ctx, articleModel.ID, uc.articleRepo.GetArticleUser(ctx, userID).ID); err != nil {
+			return err
+		}
+		// reload for the new favorites count
+		articleModel, err = uc.findArticle(ctx, slug)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	uc.articleRepo.SetFavorite(ctx, articleModel.ID, uc.articleRepo.GetArticleUser(ctx, userID).ID)
-
-	return &articleModel, err
+	// favoriting twice changes nothing
+	if created {
+		favoritesTotal.WithLabelValues("favorite").Inc()
+	}
+	return &articleModel, nil
 }
 func (uc *articleUC) DeleteFavorite(ctx context.Context, slug string, userID uint) (*models.Article, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.CreateFavorite")
-	defer span.Finish()
-
-	articleModel, err := uc.articleRepo.FindOneArticle(ctx, &models.Article{Slug: slug})
+	ctx, span := tracing.Start(ctx, "article.usecase.DeleteFavorite")
+	defer span.End()
+
+	var articleModel models.Article
+	var removed bool
+	err := uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if articleModel, err = uc.findArticle(ctx, slug); err != nil {
+			return err
+		}
+		if removed, err = uc.articleRepo.RemoveFavorite(ctx, articleModel.ID, uc.articleRepo.GetArticleUser(ctx, userID).ID); err != nil {
+			return err
+		}
+		articleModel, err = uc.findArticle(ctx, slug)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	uc.articleRepo.RemoveFavorite(ctx, articleModel.ID, uc.articleRepo.GetArticleUser(ctx, userID).ID)
-
-	return &articleModel, err
+	if removed {
+		favoritesTotal.WithLabelValues("unfavorite").Inc()
+	}
+	return &articleModel, nil
 }
 
 func (uc *articleUC) GetCommentsByArticle(ctx context.Context, slug string) ([]models.Comment, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.GetCommentsByArticle")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "article.usecase.GetCommentsByArticle")
+	defer span.End()
 
-	articleModel, err := uc.articleRepo.FindOneArticle(ctx, (&models.Article{Slug: slug}))
+	articleModel, err := uc.findArticle(ctx, slug)
 	if err != nil {
-		return nil, errors.New("Invalid slug")
-	}
-	comments, err := uc.articleRepo.GetArticleComments(ctx, articleModel)
-	if err != nil {
-		return nil, errors.New("Database error")
+		return nil, err
 	}
-	return comments, nil
+	return uc.articleRepo.GetArticleComments(ctx, articleModel)
 }
 
 func (uc *articleUC) CreateComment(ctx context.Context, slug string, userID uint, comment *models.Comment) (*models.Comment, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.CreateComment")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "article.usecase.CreateComment")
+	defer span.End()
 
-	articleModel, err := uc.articleRepo.FindOneArticle(ctx, &models.Article{Slug: slug})
+	articleModel, err := uc.findArticle(ctx, slug)
 	if err != nil {
-		return nil, errors.New("Invalid slug")
+		return nil, err
 	}
 
 	comment.Article = articleModel
 	comment.Author = uc.articleRepo.GetArticleUser(ctx, userID)
 
-	err = uc.articleRepo.SaveOne(ctx, comment)
-	return comment, err
+	if err = uc.articleRepo.SaveOne(ctx, comment); err != nil {
+		return nil, err
+	}
+	commentsTotal.Inc()
+	return comment, nil
 }
 
-func (uc *articleUC) DeleteComment(ctx context.Context, userID uint, commentIDs []uint) error {
+func (uc *articleUC) DeleteComment(ctx context.Context, userID uint, commentID uint) error {
 
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.DeleteComment")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "article.usecase.DeleteComment")
+	defer span.End()
 
-	return uc.articleRepo.DeleteComment(ctx, commentIDs)
+	commentModel, err := uc.articleRepo.FindOneComment(ctx, &models.Comment{ID: commentID})
+	if postgres.IsRecordNotFoundError(err) {
+		return article.ErrCommentNotFound
+	} else if err != nil {
+		return err
+	}
+	if commentModel.AuthorID != uc.articleRepo.GetArticleUser(ctx, userID).ID {
+		return article.ErrNotCommentAuthor
+	}
+	return uc.articleRepo.DeleteComment(ctx, []uint{commentID})
 }
 
 func (uc *articleUC) GetTags(ctx context.Context) ([]models.Tag, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "article.usecase.GetTags")
-	defer span.Finish()
-	return uc.articleRepo.GetTags()
+	ctx, span := tracing.Start(ctx, "article.usecase.GetTags")
+	defer span.End()
+	return uc.articleRepo.GetTags(ctx)
+}
+
+// Soft delete an article regardless of its author, RestoreArticle undoes it
+func (uc *articleUC) RemoveArticle(ctx context.Context, slug string) error {
+	ctx, span := tracing.Start(ctx, "article.usecase.RemoveArticle")
+	defer span.End()
+
+	articleModel, err := uc.findArticle(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return uc.articleRepo.SoftDeleteArticle(ctx, articleModel.ID)
+}
+
+func (uc *articleUC) RestoreArticle(ctx context.Context, slug string) (models.Article, error) {
+	ctx, span := tracing.Start(ctx, "article.usecase.RestoreArticle")
+	defer span.End()
+
+	restored, err := uc.articleRepo.RestoreArticle(ctx, slug)
+	if postgres.IsUniqueViolation(err) {
+		return models.Article{}, article.ErrSlugTaken
+	}
+	if err != nil {
+		return models.Article{}, err
+	}
+	if !restored {
+		return models.Article{}, article.ErrArticleNotFound
+	}
+	return uc.findArticle(ctx, slug)
+}
+
+// Retag the articles of the source tags with target, created when missing, and
+// delete the source tags. Returns how many source tags existed.
+func (uc *articleUC) MergeTags(ctx context.Context, sources []string, target string) (int, error) {
+	ctx, span := tracing.Start(ctx, "article.usecase.MergeTags")
+	defer span.End()
+
+	for _, source := range sources {
+		if source == target {
+			return 0, article.ErrInvalidTagMerge
+		}
+	}
+	var sourceTags []models.Tag
+	// a failed merge mustn't leave the target tag behind
+	err := uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if sourceTags, err = uc.articleRepo.FindTags(ctx, sources); err != nil {
+			return err
+		}
+		if len(sourceTags) == 0 {
+			return article.ErrTagNotFound
+		}
+		targetTags, err := uc.articleRepo.UpsertTags(ctx, []string{target})
+		if err != nil {
+			return err
+		}
+
+		sourceIDs := make([]uint, 0, len(sourceTags))
+		for _, tag := range sourceTags {
+			sourceIDs = append(sourceIDs, tag.ID)
+		}
+		return uc.articleRepo.MergeTags(ctx, sourceIDs, targetTags[0].ID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(sourceTags), nil
+}
+
+// Fix favorites counts that drifted from the favorites, returns how many articles changed
+func (uc *articleUC) RecomputeFavoritesCounts(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "article.usecase.RecomputeFavoritesCounts")
+	defer span.End()
+
+	return uc.articleRepo.RecomputeFavoritesCounts(ctx)
+}
+
+func (uc *articleUC) GetUserContent(ctx context.Context, userID uint) (*article.UserContent, error) {
+	ctx, span := tracing.Start(ctx, "article.usecase.GetUserContent")
+	defer span.End()
+
+	articleUserModel := uc.articleRepo.GetArticleUser(ctx, userID)
+	content := &article.UserContent{}
+	var err error
+	if content.Articles, err = uc.articleRepo.FindArticlesByAuthor(ctx, articleUserModel.ID); err != nil {
+		return nil, err
+	}
+	if content.Comments, err = uc.articleRepo.FindCommentsByAuthor(ctx, articleUserModel.ID); err != nil {
+		return nil, err
+	}
+	if content.Favorites, err = uc.articleRepo.FindFavoritedArticles(ctx, articleUserModel.ID); err != nil {
+		return nil, err
+	}
+	return content, nil
 }
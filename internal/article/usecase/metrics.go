@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	articlesPublishedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: "articles",
+			Name:      "published_total",
+			Help:      "How many articles were published.",
+		},
+	)
+	commentsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: "articles",
+			Name:      "comments_total",
+			Help:      "How many comments were written on articles.",
+		},
+	)
+	favoritesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "articles",
+			Name:      "favorites_total",
+			Help:      "How many articles were favorited and unfavorited, partitioned by action.",
+		},
+		[]string{"action"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(articlesPublishedTotal, commentsTotal, favoritesTotal)
+}
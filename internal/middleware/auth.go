@@ -1,18 +1,20 @@
 package middleware
 
 import (
-	"log"
-	"net/http"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 	"github.com/gin-gonic/gin"
 	models "github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
-	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
-	"github.com/jinzhu/gorm"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
 )
 
+var ErrSessionRequired = apperrors.Forbidden("token", errors.New("Require a session"))
+
 // Strips 'TOKEN ' prefix from token string
 func stripBearerPrefixFromTokenString(tok string) (string, error) {
 	// Should be a bearer token
@@ -25,8 +27,8 @@ func stripBearerPrefixFromTokenString(tok string) (string, error) {
 // Extract  token from Authorization header
 // Uses PostExtractionFilter to strip "TOKEN " prefix from header
 var AuthorizationHeaderExtractor = &request.PostExtractionFilter{
-	request.HeaderExtractor{"Authorization"},
-	stripBearerPrefixFromTokenString,
+	Extractor: request.HeaderExtractor{"Authorization"},
+	Filter:    stripBearerPrefixFromTokenString,
 }
 
 // Extractor for OAuth2 access tokens.  Looks in 'Authorization'
@@ -39,25 +41,44 @@ var MyAuth2Extractor = &request.MultiExtractor{
 // A helper to write user_id and user_model to the context
 func (mv *MiddlewareManager) UpdateContextUserModel(c *gin.Context, my_user_id uint, sessionId string) {
 	var myUserModel models.User
-	db := mv.db
 	if my_user_id != 0 {
-		db.First(&myUserModel, my_user_id)
+		myUserModel, _ = mv.userRepo.FindOneUser(c.Request.Context(), &models.User{ID: my_user_id})
 	}
 	c.Set("my_user_id", my_user_id)
 	c.Set("my_user_model", myUserModel)
 	c.Set("my_session_id", sessionId)
 }
 
-func (mv *MiddlewareManager) AuthMiddleware(db *gorm.DB, auto401 bool) gin.HandlerFunc {
+func (mv *MiddlewareManager) AuthMiddleware(auto401 bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		mv.UpdateContextUserModel(c, 0, "")
+		c.Set("my_token_scopes", []string(nil))
+
+		// personal access tokens aren't jwts, they are looked up by their hash
+		if raw, err := MyAuth2Extractor.ExtractToken(c.Request); err == nil && strings.HasPrefix(raw, models.AccessTokenPrefix) {
+			accessToken, err := mv.userUC.AuthenticateAccessToken(c.Request.Context(), raw)
+			if err != nil {
+				if auto401 {
+					c.Error(err)
+					c.Abort()
+				}
+				return
+			}
+			mv.UpdateContextUserModel(c, accessToken.UserID, "")
+			c.Set("my_token_scopes", accessToken.ScopeList())
+			return
+		}
+
 		token, err := request.ParseFromRequest(c.Request, MyAuth2Extractor, func(token *jwt.Token) (interface{}, error) {
-			b := ([]byte(utils.NBSecretPassword))
-			return b, nil
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("Unexpected signing method %v", token.Header["alg"])
+			}
+			return []byte(mv.cfg.Server.JwtSecretKey), nil
 		})
 		if err != nil {
 			if auto401 {
-				c.AbortWithError(http.StatusUnauthorized, err)
+				c.Error(apperrors.Unauthorized("auth", errors.New("Invalid token")))
+				c.Abort()
 			}
 			return
 		}
@@ -68,12 +89,9 @@ func (mv *MiddlewareManager) AuthMiddleware(db *gorm.DB, auto401 bool) gin.Handl
 			// check token is active
 			sess, err := mv.sessUC.GetSessionByID(c.Request.Context(), session_id)
 			if err != nil {
-				log.Printf("GetSessionByID RequestID: %s, CookieValue: %s, Error: %s",
-					utils.GetRequestID(c),
-					token.Raw,
-					err.Error(),
-				)
-				c.AbortWithError(http.StatusUnauthorized, err)
+				logger.FromContext(c.Request.Context()).Warnf("GetSessionByID SessionID: %s, Error: %s", session_id, err.Error())
+				c.Error(apperrors.Unauthorized("auth", errors.New("Session expired")))
+				c.Abort()
 				return
 			}
 			//fmt.Println(my_user_id,claims["id"])
@@ -81,3 +99,34 @@ func (mv *MiddlewareManager) AuthMiddleware(db *gorm.DB, auto401 bool) gin.Handl
 		}
 	}
 }
+
+// Requests authenticated by a personal access token need the scope, sessions have all scopes
+func (mv *MiddlewareManager) ScopeMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, _ := c.Get("my_token_scopes")
+		if scopes, ok := scopes.([]string); ok && scopes != nil {
+			for _, s := range scopes {
+				if s == scope {
+					c.Next()
+					return
+				}
+			}
+			c.Error(apperrors.Forbidden("token", fmt.Errorf("Missing scope %s", scope)))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Reject personal access tokens, for routes which need a real session
+func (mv *MiddlewareManager) SessionOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("my_session_id") == "" {
+			c.Error(ErrSessionRequired)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+)
+
+var (
+	ErrOriginNotAllowed = apperrors.Forbidden("cors", errors.New("Origin not allowed"))
+	ErrMethodNotAllowed = apperrors.Forbidden("cors", errors.New("Method not allowed"))
+)
+
+const regexOriginPrefix = "regex:"
+
+// Matches the Origin header against one entry of the origins list:
+// "*", an exact origin, a wildcard subdomain like "https://*.example.com"
+// or a regular expression prefixed with "regex:" which has to match the whole origin
+type originMatcher func(origin string) bool
+
+func newOriginMatcher(pattern string) (originMatcher, error) {
+	switch {
+	case pattern == "*":
+		return func(string) bool { return true }, nil
+	case strings.HasPrefix(pattern, regexOriginPrefix):
+		// anchored, https://app\.example\.com mustn't let https://app.example.com.evil.io in
+		re, err := regexp.Compile(`^(?:` + strings.TrimPrefix(pattern, regexOriginPrefix) + `)$`)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	case strings.Contains(pattern, "://*."):
+		scheme, host, _ := strings.Cut(pattern, "://*.")
+		return func(origin string) bool {
+			rest, ok := strings.CutPrefix(strings.ToLower(origin), strings.ToLower(scheme)+"://")
+			return ok && strings.HasSuffix(rest, "."+strings.ToLower(host))
+		}, nil
+	default:
+		return func(origin string) bool { return strings.EqualFold(origin, pattern) }, nil
+	}
+}
+
+// Cross origin resource sharing for the configured origins, requests from other origins are rejected
+func (mv *MiddlewareManager) CorsMiddleware() gin.HandlerFunc {
+	matchers := make([]originMatcher, 0, len(mv.origins))
+	for _, pattern := range mv.origins {
+		matcher, err := newOriginMatcher(pattern)
+		if err != nil {
+			mv.logger.Fatalf("CorsMiddleware: invalid origin %q: %v", pattern, err)
+		}
+		matchers = append(matchers, matcher)
+	}
+	allowed := func(origin string) bool {
+		for _, match := range matchers {
+			if match(origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	cors := mv.cfg.Cors
+	allowMethods := strings.Join(cors.Methods, ", ")
+	allowHeaders := strings.Join(cors.Headers, ", ")
+	exposeHeaders := strings.Join(cors.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(cors.MaxAge)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		if !allowed(origin) {
+			c.Error(ErrOriginNotAllowed)
+			c.Abort()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if cors.Credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		// Preflight
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			if !containsFold(cors.Methods, c.GetHeader("Access-Control-Request-Method")) {
+				c.Error(ErrMethodNotAllowed)
+				c.Abort()
+				return
+			}
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			if cors.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposeHeaders != "" {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		c.Next()
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
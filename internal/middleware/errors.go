@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/httpErrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+)
+
+// Render the last error handlers added with c.Error, unless they already wrote a response.
+// Errors use the RealWorld {"errors": {...}} shape, or problem details when configured
+// or asked for with "Accept: application/problem+json". Validation messages
+// follow the Accept-Language header.
+func (mv *MiddlewareManager) ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		status := http.StatusInternalServerError
+		var body httpErrors.CommonError
+		var validationErrs validator.ValidationErrors
+		appErr, ok := apperrors.As(err)
+		switch {
+		case !ok:
+			// Internal errors are logged, not shown
+			logger.FromContext(utils.GetRequestCtx(c)).Errorf("ErrorMiddleware: %v", err)
+			body = httpErrors.NewError("server", errors.New(http.StatusText(status)))
+		case errors.As(appErr.Err, &validationErrs):
+			status = appErr.Status()
+			trans := httpErrors.GetTranslator(c.GetHeader("Accept-Language"))
+			if trans != nil {
+				c.Header("Content-Language", strings.ReplaceAll(trans.Locale(), "_", "-"))
+			}
+			body = httpErrors.NewValidatorError(validationErrs, trans)
+		default:
+			status = appErr.Status()
+			body = httpErrors.NewError(appErr.Key, appErr.Err)
+			// the access log already has timed out and cancelled requests
+			if status >= http.StatusInternalServerError && appErr != ErrRequestTimeout && appErr != ErrRequestCancelled {
+				logger.FromContext(utils.GetRequestCtx(c)).Errorf("ErrorMiddleware: %v", err)
+			}
+		}
+		if ok && appErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+		}
+
+		if mv.cfg.Server.ProblemDetails || httpErrors.AcceptsProblem(c.GetHeader("Accept")) {
+			detail := http.StatusText(status)
+			if ok && status < http.StatusInternalServerError {
+				detail = appErr.Error()
+			}
+			c.Header("Content-Type", httpErrors.ProblemContentType)
+			c.JSON(status, httpErrors.NewProblem(status, detail, c.Request.URL.Path, body))
+			return
+		}
+		c.JSON(status, body)
+	}
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/idempotency"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/locker"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyLockTTLPadding = 5 * time.Second
+)
+
+var (
+	ErrIdempotencyKeyInvalid  = apperrors.Validation("idempotencyKey", errors.New("Must be at most 255 characters"))
+	ErrIdempotencyKeyInFlight = apperrors.Conflict("idempotencyKey", errors.New("A request with this key is still in progress"))
+	ErrIdempotencyKeyReused   = apperrors.Validation("idempotencyKey", errors.New("Key was already used for a different request"))
+	ErrIdempotencyUnavailable = apperrors.Unavailable("idempotencyKey", errors.New("Try again later"))
+)
+
+// Keeps a copy of the response body so it can be stored for replays
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Make POST requests with an "Idempotency-Key" header safe to retry. The first
+// response the handler writes is stored and replayed to retries of the same
+// request, a request reusing the key with a different body gets 422 and one
+// sent while the first is still running gets 409. Keys are scoped to the
+// signed in user. Server errors aren't stored so the request can be retried.
+func (mv *MiddlewareManager) IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.Error(ErrIdempotencyKeyInvalid)
+			c.Abort()
+			return
+		}
+
+		ctx := utils.GetRequestCtx(c)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Error(apperrors.Validation("body", err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := idempotency.Fingerprint(c.Request.Method, c.Request.URL.Path, body)
+		storeKey := fmt.Sprintf("%d:%s", c.GetUint("my_user_id"), key)
+
+		if mv.replayIdempotentResponse(c, storeKey, fingerprint) {
+			return
+		}
+
+		lock, err := mv.locker.ObtainLock(ctx, "idempotency:"+storeKey,
+			locker.WithTTL(mv.cfg.Server.CtxDefaultTimeout*time.Second+idempotencyLockTTLPadding), locker.WithWait(0))
+		if err == locker.ErrNotObtained {
+			c.Error(ErrIdempotencyKeyInFlight)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			logger.FromContext(ctx).Errorf("IdempotencyMiddleware.ObtainLock: %v", err)
+			c.Error(ErrIdempotencyUnavailable)
+			c.Abort()
+			return
+		}
+		defer lock.Release(ctx)
+
+		// the first request may have finished between the lookup and the lock
+		if mv.replayIdempotentResponse(c, storeKey, fingerprint) {
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if !writer.Written() || writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		err = mv.idempotencyStore.Save(ctx, storeKey, &idempotency.Response{
+			Fingerprint: fingerprint,
+			Status:      writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		})
+		if err != nil {
+			logger.FromContext(ctx).Errorf("IdempotencyMiddleware.Save: %v", err)
+		}
+	}
+}
+
+// Write the stored response of key, reports whether the request was handled
+func (mv *MiddlewareManager) replayIdempotentResponse(c *gin.Context, key, fingerprint string) bool {
+	stored, err := mv.idempotencyStore.Get(utils.GetRequestCtx(c), key)
+	if err == idempotency.ErrNotFound {
+		return false
+	}
+	if err != nil {
+		logger.FromContext(utils.GetRequestCtx(c)).Errorf("IdempotencyMiddleware.Get: %v", err)
+		c.Error(ErrIdempotencyUnavailable)
+		c.Abort()
+		return true
+	}
+	if stored.Fingerprint != fingerprint {
+		c.Error(ErrIdempotencyKeyReused)
+		c.Abort()
+		return true
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(stored.Status, stored.ContentType, stored.Body)
+	c.Abort()
+	return true
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attach a logger carrying the request id and trace id to the request and write the
+// access log, must run after the request id and tracing middlewares
+func (mv *MiddlewareManager) RequestLoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		reqLogger := mv.logger.With("requestId", utils.GetRequestID(c))
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			reqLogger = reqLogger.With("traceId", sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), reqLogger))
+
+		c.Next()
+
+		accessLogger := logger.FromContext(utils.GetRequestCtx(c)).With(
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start).String(),
+			"clientIp", c.ClientIP(),
+			"size", c.Writer.Size(),
+		)
+		if len(c.Errors) > 0 {
+			accessLogger = accessLogger.With("errors", c.Errors.String())
+		}
+		switch {
+		case c.Writer.Status() >= 500:
+			accessLogger.Error("request")
+		case c.Writer.Status() >= 400:
+			accessLogger.Warn("request")
+		default:
+			accessLogger.Info("request")
+		}
+	}
+}
@@ -1,21 +1,30 @@
 package middleware
 
 import (
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
-	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/idempotency"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/locker"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/ratelimit"
 )
 
 // Middleware manager
 type MiddlewareManager struct {
-	db     *postgres.DB
-	sessUC session.UseCase
+	userRepo user.Repository
+	sessUC   session.UseCase
+	userUC   user.UseCase
+	limiter  ratelimit.Limiter
 	// authUC auth.UseCase
-	// cfg     *config.Config
-	origins []string
-	// logger  logger.Logger
+	cfg              *config.Config
+	origins          []string
+	logger           logger.Logger
+	locker           locker.Locker
+	idempotencyStore idempotency.Store
 }
 
 // Middleware manager constructor
-func NewMiddlewareManager(db *postgres.DB, sessUC session.UseCase, origins []string) *MiddlewareManager {
-	return &MiddlewareManager{db: db, sessUC: sessUC, origins: origins}
+func NewMiddlewareManager(cfg *config.Config, userRepo user.Repository, sessUC session.UseCase, userUC user.UseCase, limiter ratelimit.Limiter, origins []string, logger logger.Logger, locker locker.Locker, idempotencyStore idempotency.Store) *MiddlewareManager {
+	return &MiddlewareManager{cfg: cfg, userRepo: userRepo, sessUC: sessUC, userUC: userUC, limiter: limiter, origins: origins, logger: logger, locker: locker, idempotencyStore: idempotencyStore}
 }
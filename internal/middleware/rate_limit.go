@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/ratelimit"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var throttledRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "gin",
+		Name:      "ratelimit_throttled_total",
+		Help:      "How many requests were rejected by the rate limiter, partitioned by policy and identity kind.",
+	},
+	[]string{"policy", "identity"},
+)
+
+func init() {
+	prometheus.MustRegister(throttledRequests)
+}
+
+// Limit requests of the route group by the named policy, must run after AuthMiddleware.
+// Signed in users are limited by user id, anonymous ones by client ip.
+// A policy missing from the config disables limiting for the group.
+func (mv *MiddlewareManager) RateLimitMiddleware(policy string) gin.HandlerFunc {
+	policyCfg, ok := mv.cfg.RateLimit.Policies[policy]
+	if !ok || policyCfg.Rate <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	limit := ratelimit.Limit{
+		Rate:   policyCfg.Rate,
+		Period: time.Duration(policyCfg.Period) * time.Second,
+		Burst:  policyCfg.Burst,
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = 1
+	}
+
+	return func(c *gin.Context) {
+		identity, key := "ip", c.ClientIP()
+		if myUserID := c.GetUint("my_user_id"); myUserID != 0 {
+			identity, key = "user", strconv.FormatUint(uint64(myUserID), 10)
+		}
+
+		res, err := mv.limiter.Allow(c.Request.Context(), fmt.Sprintf("%s:%s:%s", policy, identity, key), limit)
+		if err != nil {
+			// Fail open, an unavailable redis shouldn't take the api down with it
+			logger.FromContext(utils.GetRequestCtx(c)).Errorf("RateLimitMiddleware: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", limit.Rate, policyCfg.Period, limit.Burst))
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+		if !res.Allowed {
+			throttledRequests.WithLabelValues(policy, identity).Inc()
+			// ErrorMiddleware sets Retry-After
+			c.Error(apperrors.TooManyRequests("rateLimit", errors.New("Too many requests"), res.RetryAfter))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+)
+
+var (
+	ErrAuthRequired     = apperrors.Unauthorized("auth", errors.New("Require auth!"))
+	ErrPermissionDenied = apperrors.Forbidden("auth", errors.New("Permission denied"))
+)
+
+// Only let users with the given role through, must run after AuthMiddleware
+func (mv *MiddlewareManager) RoleMiddleware(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		myUserModel := c.MustGet("my_user_model").(models.User)
+		if myUserModel.ID == 0 {
+			c.Error(ErrAuthRequired)
+			c.Abort()
+			return
+		}
+		if myUserModel.Role != role {
+			c.Error(ErrPermissionDenied)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
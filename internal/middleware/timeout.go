@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+)
+
+var (
+	ErrRequestTimeout   = apperrors.Timeout("server", errors.New("Request timed out"))
+	ErrRequestCancelled = apperrors.Unavailable("server", errors.New("Request cancelled"))
+)
+
+// Give each request a deadline of Server.CtxDefaultTimeout seconds. Repositories
+// stop their database and redis work once the deadline passes or the client
+// goes away, the request then fails with 504 or 503 instead of whatever error
+// the interrupted query left behind.
+func (mv *MiddlewareManager) TimeoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mv.cfg.Server.CtxDefaultTimeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), mv.cfg.Server.CtxDefaultTimeout*time.Second)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if c.Writer.Written() {
+			return
+		}
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			c.Error(ErrRequestTimeout)
+		case context.Canceled:
+			c.Error(ErrRequestCancelled)
+		}
+	}
+}
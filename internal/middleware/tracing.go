@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TraceIDHeader = "X-Trace-Id"
+
+// Start the server span of the request, continuing the trace of an incoming W3C
+// traceparent header. The trace id is sent back in X-Trace-Id, must run after the
+// request id middleware.
+func (mv *MiddlewareManager) TracingMiddleware() gin.HandlerFunc {
+	propagator := tracing.Propagator()
+	return func(c *gin.Context) {
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method
+		}
+		ctx, span := tracing.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+				attribute.String("requestId", utils.GetRequestID(c)),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			c.Header(TraceIDHeader, sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if myUserID := c.GetUint("my_user_id"); myUserID != 0 {
+			span.SetAttributes(semconv.EnduserID(strconv.FormatUint(uint64(myUserID), 10)))
+		}
+		// client errors are the client's problem, the span only fails on server errors
+		if status >= http.StatusInternalServerError {
+			if err := c.Errors.Last(); err != nil {
+				span.RecordError(err.Err)
+			}
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
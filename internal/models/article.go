@@ -14,9 +14,13 @@ type Article struct {
 	AuthorID    uint
 	Tags        []Tag     `gorm:"many2many:article_tags;"`
 	Comments    []Comment `gorm:"ForeignKey:ArticleID"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   *time.Time `sql:"index" json:"deleted_at"`
+	// Kept by the favorite repository methods, only written by them
+	FavoritesCount uint `gorm:"not null;default:0"`
+	// Bumped by every update, edits based on an older version are rejected
+	Version   uint `gorm:"not null;default:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `sql:"index" json:"deleted_at"`
 }
 
 func (e *Article) TableName() string {
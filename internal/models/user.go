@@ -2,11 +2,17 @@ package models
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID           uint    `gorm:"primary_key"`
 	Username     string  `gorm:"column:username"`
@@ -14,6 +20,9 @@ type User struct {
 	Bio          string  `gorm:"column:bio;size:1024"`
 	Image        *string `gorm:"column:image"`
 	PasswordHash string  `gorm:"column:password;not null"`
+	Role         string  `gorm:"column:role;not null;default:'user'"`
+	TotpSecret   string  `gorm:"column:totp_secret"`
+	TotpEnabled  bool    `gorm:"column:totp_enabled;not null;default:false"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
@@ -42,6 +51,106 @@ func (u *User) CheckPassword(password string) error {
 	return bcrypt.CompareHashAndPassword(byteHashedPassword, bytePassword)
 }
 
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Recorded when an account gets locked after too many failed logins,
+// the owner is told about unseen lockouts on the next successful login.
+type LoginLockout struct {
+	ID          uint `gorm:"primary_key"`
+	UserID      uint `gorm:"index"`
+	IP          string
+	LockedUntil time.Time
+	Seen        bool
+	CreatedAt   time.Time
+}
+
+func (e *LoginLockout) TableName() string {
+	return "login_lockout_models"
+}
+
+// One time recovery code for two factor auth, only the hash is stored
+type RecoveryCode struct {
+	ID        uint   `gorm:"primary_key"`
+	UserID    uint   `gorm:"index"`
+	CodeHash  string `gorm:"column:code_hash;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (e *RecoveryCode) TableName() string {
+	return "recovery_code_models"
+}
+
+const (
+	// prefix of personal access tokens, so they can be told apart from session jwts
+	AccessTokenPrefix = "rwpat_"
+
+	ScopeReadArticles  = "articles:read"
+	ScopeWriteArticles = "articles:write"
+	ScopeManageProfile = "profile:manage"
+)
+
+var AccessTokenScopes = []string{ScopeReadArticles, ScopeWriteArticles, ScopeManageProfile}
+
+// Named token for scripts and CI, only the hash of the token is stored
+type PersonalAccessToken struct {
+	ID         uint   `gorm:"primary_key"`
+	UserID     uint   `gorm:"index"`
+	Name       string `gorm:"not null"`
+	TokenHash  string `gorm:"column:token_hash;unique_index;not null"`
+	Scopes     string `gorm:"not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (e *PersonalAccessToken) TableName() string {
+	return "personal_access_token_models"
+}
+
+func (e *PersonalAccessToken) ScopeList() []string {
+	if e.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(e.Scopes, ",")
+}
+
+func (e *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range e.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *PersonalAccessToken) IsExpired() bool {
+	return e.ExpiresAt != nil && e.ExpiresAt.Before(time.Now())
+}
+
+// Account of an external OpenID Connect provider linked to a user
+type UserIdentity struct {
+	ID        uint   `gorm:"primary_key"`
+	UserID    uint   `gorm:"index"`
+	Provider  string `gorm:"unique_index:idx_provider_subject;not null"`
+	Subject   string `gorm:"unique_index:idx_provider_subject;not null"`
+	Email     string
+	CreatedAt time.Time
+}
+
+func (e *UserIdentity) TableName() string {
+	return "user_identity_models"
+}
+
+// Pending authorization request, kept until the provider redirects back
+type OAuthState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
 // A hack way to save ManyToMany relationship,
 // gorm will build the alias as FollowingBy <-> FollowingByID <-> "following_by_id".
 //
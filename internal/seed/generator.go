@@ -0,0 +1,308 @@
+// Deterministic datasets for benchmarks and load tests. The same options always
+// generate the same users, follows, articles, tags, favorites and comments.
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+)
+
+// Skew of the popularity of users, articles and tags, higher is more skewed
+const zipfSkew = 1.2
+
+// The smallest text column, the bio
+const maxTextSize = 1024
+
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor
+	incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco
+	laboris nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit esse
+	cillum fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui officia
+	deserunt mollit anim id est laborum`)
+
+var tagWords = strings.Fields(`golang rust python javascript typescript java kotlin swift react vue angular
+	svelte docker kubernetes terraform postgres redis kafka graphql grpc rest testing security performance
+	design architecture devops cloud aws gcp azure linux networking databases career productivity opensource`)
+
+type Options struct {
+	Seed       int64
+	Users      int
+	Articles   int
+	Tags       int
+	Favorites  int
+	Comments   int
+	MaxFollows int
+	// Usernames, emails and slugs contain it, datasets of another prefix don't collide
+	Prefix string
+	// Everything is created in the year after Start
+	Start time.Time
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Seed:       1,
+		Users:      1000,
+		Articles:   5000,
+		Tags:       50,
+		Favorites:  20000,
+		Comments:   10000,
+		MaxFollows: 200,
+		Prefix:     "seed",
+		Start:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (o Options) Validate() error {
+	if o.Users < 1 || o.Tags < 0 || o.Articles < 0 || o.Favorites < 0 || o.Comments < 0 || o.MaxFollows < 0 {
+		return fmt.Errorf("seed needs at least one user and no negative counts")
+	}
+	if o.Articles > 0 && o.Tags < 1 {
+		return fmt.Errorf("seed needs tags for the articles")
+	}
+	if o.Prefix == "" {
+		return fmt.Errorf("seed needs a prefix")
+	}
+	return nil
+}
+
+// The relations refer to users, articles and tags by their index in the dataset
+type User struct {
+	Username  string
+	Email     string
+	Bio       string
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	Following  int
+	FollowedBy int
+}
+
+type Article struct {
+	Author         int
+	Slug           string
+	Title          string
+	Description    string
+	Body           string
+	Tags           []int
+	FavoritesCount uint
+	CreatedAt      time.Time
+}
+
+type Favorite struct {
+	Article   int
+	User      int
+	CreatedAt time.Time
+}
+
+type Comment struct {
+	Article   int
+	Author    int
+	Body      string
+	CreatedAt time.Time
+}
+
+type Dataset struct {
+	Users     []User
+	Follows   []Follow
+	Tags      []string
+	Articles  []Article
+	Favorites []Favorite
+	Comments  []Comment
+}
+
+type generator struct {
+	opts   Options
+	rand   *rand.Rand
+	period time.Duration
+}
+
+// Generate the dataset of opts. Followers, authorship, favorites and tags follow power
+// laws, a few users and articles get most of the attention like in real data.
+func Generate(opts Options) (*Dataset, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	g := &generator{opts: opts, rand: rand.New(rand.NewSource(opts.Seed)), period: 365 * 24 * time.Hour}
+	data := &Dataset{}
+	data.Users = g.users()
+	data.Follows = g.follows()
+	data.Tags = g.tags()
+	data.Articles = g.articles(data.Users)
+	data.Favorites = g.favorites(data.Articles)
+	data.Comments = g.comments(data.Articles)
+	return data, nil
+}
+
+// Picks indexes below n, a few of them much more often than the rest
+type popularity struct {
+	zipf  *rand.Zipf
+	ranks []int
+}
+
+func (g *generator) popularity(n int) *popularity {
+	return &popularity{zipf: rand.NewZipf(g.rand, zipfSkew, 1, uint64(n-1)), ranks: g.rand.Perm(n)}
+}
+
+func (p *popularity) pick() int {
+	return p.ranks[p.zipf.Uint64()]
+}
+
+// A time between after and the end of the period
+func (g *generator) timeAfter(after time.Time) time.Time {
+	end := g.opts.Start.Add(g.period)
+	if !after.Before(end) {
+		return after
+	}
+	return after.Add(time.Duration(g.rand.Int63n(int64(end.Sub(after))))).Truncate(time.Second)
+}
+
+// Random words, the size of the text columns caps them
+func (g *generator) text(minWords, maxWords int) string {
+	n := minWords + g.rand.Intn(maxWords-minWords+1)
+	var text strings.Builder
+	for i := 0; i < n; i++ {
+		word := words[g.rand.Intn(len(words))]
+		if text.Len()+len(word)+1 > maxTextSize {
+			break
+		}
+		if i > 0 {
+			text.WriteByte(' ')
+		}
+		text.WriteString(word)
+	}
+	s := text.String()
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func (g *generator) users() []User {
+	users := make([]User, g.opts.Users)
+	for i := range users {
+		username := fmt.Sprintf("%s%d", g.opts.Prefix, i+1)
+		users[i] = User{
+			Username: username,
+			Email:    username + "@example.com",
+			Bio:      g.text(5, 20),
+			// earlier users signed up earlier
+			CreatedAt: g.opts.Start.Add(g.period / 2 * time.Duration(i) / time.Duration(len(users))),
+		}
+	}
+	return users
+}
+
+// Most users follow a few others, and a few users are followed by most
+func (g *generator) follows() []Follow {
+	n := g.opts.Users
+	maxFollows := g.opts.MaxFollows
+	if maxFollows > n-1 {
+		maxFollows = n - 1
+	}
+	if maxFollows == 0 {
+		return nil
+	}
+	outDegree := rand.NewZipf(g.rand, zipfSkew, 1, uint64(maxFollows))
+	followed := g.popularity(n)
+
+	var follows []Follow
+	seen := map[Follow]bool{}
+	for follower := 0; follower < n; follower++ {
+		count := int(outDegree.Uint64())
+		for attempts := 0; count > 0 && attempts < 4*maxFollows; attempts++ {
+			follow := Follow{Following: followed.pick(), FollowedBy: follower}
+			if follow.Following == follower || seen[follow] {
+				continue
+			}
+			seen[follow] = true
+			follows = append(follows, follow)
+			count--
+		}
+	}
+	return follows
+}
+
+func (g *generator) tags() []string {
+	tags := make([]string, g.opts.Tags)
+	for i := range tags {
+		tags[i] = tagWords[i%len(tagWords)]
+		if round := i / len(tagWords); round > 0 {
+			tags[i] = fmt.Sprintf("%s-%d", tags[i], round+1)
+		}
+	}
+	return tags
+}
+
+func (g *generator) articles(users []User) []Article {
+	articles := make([]Article, g.opts.Articles)
+	if len(articles) == 0 {
+		return articles
+	}
+	authors := g.popularity(len(users))
+	tags := g.popularity(g.opts.Tags)
+	for i := range articles {
+		author := authors.pick()
+		title := g.text(3, 8)
+		articles[i] = Article{
+			Author:      author,
+			Slug:        slug.Make(fmt.Sprintf("%s %s %d", title, g.opts.Prefix, i+1)),
+			Title:       title,
+			Description: g.text(10, 30),
+			Body:        g.text(50, 250),
+			CreatedAt:   g.timeAfter(users[author].CreatedAt),
+		}
+		count := 1 + g.rand.Intn(5)
+		if count > g.opts.Tags {
+			count = g.opts.Tags
+		}
+		seen := map[int]bool{}
+		for attempts := 0; len(articles[i].Tags) < count && attempts < 20; attempts++ {
+			if tag := tags.pick(); !seen[tag] {
+				seen[tag] = true
+				articles[i].Tags = append(articles[i].Tags, tag)
+			}
+		}
+	}
+	return articles
+}
+
+// Favorites by any user, mostly of the popular articles. The favorites count of the articles matches them.
+func (g *generator) favorites(articles []Article) []Favorite {
+	if len(articles) == 0 {
+		return nil
+	}
+	popular := g.popularity(len(articles))
+	var favorites []Favorite
+	seen := map[[2]int]bool{}
+	for attempts := 0; len(favorites) < g.opts.Favorites && attempts < 4*g.opts.Favorites; attempts++ {
+		favorite := Favorite{Article: popular.pick(), User: g.rand.Intn(g.opts.Users)}
+		pair := [2]int{favorite.Article, favorite.User}
+		if seen[pair] {
+			continue
+		}
+		seen[pair] = true
+		favorite.CreatedAt = g.timeAfter(articles[favorite.Article].CreatedAt)
+		articles[favorite.Article].FavoritesCount++
+		favorites = append(favorites, favorite)
+	}
+	return favorites
+}
+
+func (g *generator) comments(articles []Article) []Comment {
+	if len(articles) == 0 {
+		return nil
+	}
+	popular := g.popularity(len(articles))
+	comments := make([]Comment, g.opts.Comments)
+	for i := range comments {
+		articleIndex := popular.pick()
+		comments[i] = Comment{
+			Article:   articleIndex,
+			Author:    g.rand.Intn(g.opts.Users),
+			Body:      g.text(5, 60),
+			CreatedAt: g.timeAfter(articles[articleIndex].CreatedAt),
+		}
+	}
+	return comments
+}
@@ -0,0 +1,56 @@
+package seed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func smallOptions() Options {
+	opts := DefaultOptions()
+	opts.Users = 50
+	opts.Articles = 100
+	opts.Tags = 10
+	opts.Favorites = 300
+	opts.Comments = 200
+	opts.MaxFollows = 20
+	return opts
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	first, err := Generate(smallOptions())
+	require.NoError(t, err)
+	second, err := Generate(smallOptions())
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	opts := smallOptions()
+	opts.Seed = 2
+	other, err := Generate(opts)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
+
+func TestGenerateSingleUserAndTag(t *testing.T) {
+	opts := smallOptions()
+	opts.Users = 1
+	opts.Tags = 1
+
+	data, err := Generate(opts)
+	require.NoError(t, err)
+	assert.Len(t, data.Users, 1)
+	assert.Empty(t, data.Follows)
+	assert.Equal(t, []string{"golang"}, data.Tags)
+	require.Len(t, data.Articles, opts.Articles)
+	for _, article := range data.Articles {
+		assert.Equal(t, 0, article.Author)
+		assert.Equal(t, []int{0}, article.Tags)
+	}
+	// the only user favorites each article at most once
+	assert.LessOrEqual(t, len(data.Favorites), opts.Articles)
+	for _, favorite := range data.Favorites {
+		assert.Equal(t, 0, favorite.User)
+	}
+	assert.Len(t, data.Comments, opts.Comments)
+}
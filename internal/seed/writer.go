@@ -0,0 +1,104 @@
+package seed
+
+import (
+	"context"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
+)
+
+// Ids the rows of a dataset got, in the order of the dataset
+type Written struct {
+	UserIDs    []uint
+	ArticleIDs []uint
+}
+
+// Insert data in a single transaction with bulk inserts, all users get passwordHash.
+// Tags that exist already are reused, the other rows must not exist yet.
+func Write(ctx context.Context, db *postgres.DB, data *Dataset, passwordHash string) (*Written, error) {
+	tx, err := db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	written := &Written{}
+	rows := make([][]interface{}, 0, len(data.Users))
+	for _, u := range data.Users {
+		rows = append(rows, []interface{}{u.Username, u.Email, u.Bio, passwordHash, models.RoleUser, u.CreatedAt, u.CreatedAt})
+	}
+	written.UserIDs, err = postgres.BulkInsertReturningIDs(ctx, tx, "user_models",
+		[]string{"username", "email", "bio", "password", "role", "created_at", "updated_at"}, rows, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// articles and favorites refer to the article user of a user
+	rows = rows[:0]
+	for i, u := range data.Users {
+		rows = append(rows, []interface{}{written.UserIDs[i], u.CreatedAt, u.CreatedAt})
+	}
+	articleUserIDs, err := postgres.BulkInsertReturningIDs(ctx, tx, "article_user_models",
+		[]string{"user_id", "created_at", "updated_at"}, rows, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rows = make([][]interface{}, 0, len(data.Follows))
+	for _, f := range data.Follows {
+		rows = append(rows, []interface{}{written.UserIDs[f.Following], written.UserIDs[f.FollowedBy]})
+	}
+	if err = postgres.BulkInsert(ctx, tx, "follow_models", []string{"following_id", "followed_by_id"}, rows, ""); err != nil {
+		return nil, err
+	}
+
+	rows = make([][]interface{}, 0, len(data.Tags))
+	for _, tag := range data.Tags {
+		rows = append(rows, []interface{}{tag, data.Users[0].CreatedAt, data.Users[0].CreatedAt})
+	}
+	tagIDs, err := postgres.BulkInsertReturningIDs(ctx, tx, "tag_models", []string{"tag", "created_at", "updated_at"}, rows,
+		"ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag")
+	if err != nil {
+		return nil, err
+	}
+
+	rows = make([][]interface{}, 0, len(data.Articles))
+	for _, a := range data.Articles {
+		rows = append(rows, []interface{}{a.Slug, a.Title, a.Description, a.Body, articleUserIDs[a.Author], a.FavoritesCount, a.CreatedAt, a.CreatedAt})
+	}
+	written.ArticleIDs, err = postgres.BulkInsertReturningIDs(ctx, tx, "article_models",
+		[]string{"slug", "title", "description", "body", "author_id", "favorites_count", "created_at", "updated_at"}, rows, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rows = rows[:0]
+	for i, a := range data.Articles {
+		for _, tag := range a.Tags {
+			rows = append(rows, []interface{}{written.ArticleIDs[i], tagIDs[tag]})
+		}
+	}
+	if err = postgres.BulkInsert(ctx, tx, "article_tags", []string{"article_id", "tag_id"}, rows, ""); err != nil {
+		return nil, err
+	}
+
+	rows = make([][]interface{}, 0, len(data.Favorites))
+	for _, f := range data.Favorites {
+		rows = append(rows, []interface{}{written.ArticleIDs[f.Article], articleUserIDs[f.User], f.CreatedAt, f.CreatedAt})
+	}
+	if err = postgres.BulkInsert(ctx, tx, "favorite_models",
+		[]string{"favorite_id", "favorite_by_id", "created_at", "updated_at"}, rows, ""); err != nil {
+		return nil, err
+	}
+
+	rows = make([][]interface{}, 0, len(data.Comments))
+	for _, c := range data.Comments {
+		rows = append(rows, []interface{}{written.ArticleIDs[c.Article], articleUserIDs[c.Author], c.Body, c.CreatedAt, c.CreatedAt})
+	}
+	if err = postgres.BulkInsert(ctx, tx, "comment_models",
+		[]string{"article_id", "author_id", "body", "created_at", "updated_at"}, rows, ""); err != nil {
+		return nil, err
+	}
+
+	return written, tx.Commit()
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	sessUsecase "github.com/gothinkster/golang-gin-realworld-example-app/internal/session/usecase"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var buildInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "app_build_info",
+		Help: "Always 1, labelled with the version, commit and go version of the running binary.",
+	},
+	[]string{"version", "commit", "goversion"},
+)
+
+func init() {
+	prometheus.MustRegister(buildInfo)
+}
+
+// Routes of the admin server, it listens on the metrics address apart from the api
+// so profiles, config and log levels are never reachable from the public interface
+func (s *Server) MapAdminHandlers(engine *gin.Engine) {
+	engine.Use(gin.Recovery())
+	if s.cfg.Metrics.Username != "" {
+		engine.Use(gin.BasicAuth(gin.Accounts{s.cfg.Metrics.Username: s.cfg.Metrics.Password}))
+	}
+
+	info := utils.GetBuildInfo(s.cfg.Server.AppVersion)
+	buildInfo.WithLabelValues(info.Version, info.Commit, info.GoVersion).Set(1)
+
+	// collectors of this server's storage, the package level metrics are in the default registry
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(sessUsecase.NewActiveSessionsCollector(s.storage.sessRepo))
+	metrics := promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, registry}, promhttp.HandlerOpts{})
+	engine.GET("/metrics", gin.WrapH(promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, metrics)))
+	engine.GET("/buildinfo", func(c *gin.Context) {
+		c.JSON(http.StatusOK, info)
+	})
+	engine.GET("/config", func(c *gin.Context) {
+		dump, err := config.Redacted(s.cfg)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, dump)
+	})
+	engine.GET("/loglevel", gin.WrapH(logger.Level()))
+	engine.PUT("/loglevel", gin.WrapH(logger.Level()))
+	engine.GET("/debug/pprof/*profile", pprofHandler)
+	engine.POST("/debug/pprof/*profile", pprofHandler)
+}
+
+// net/http/pprof under one route, gin doesn't allow static routes next to a catch all
+func pprofHandler(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("profile"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// the index and named profiles like heap and goroutine
+		pprof.Index(c.Writer, c.Request)
+	}
+}
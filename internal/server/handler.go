@@ -7,59 +7,76 @@ import (
 	requestid "github.com/sumit-tembe/gin-requestid"
 
 	articleHttp "github.com/gothinkster/golang-gin-realworld-example-app/internal/article/delivery/http"
-	articleRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/article/repository"
 	articleUsecase "github.com/gothinkster/golang-gin-realworld-example-app/internal/article/usecase"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/middleware"
-	sessRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/session/repository"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	sessUsecase "github.com/gothinkster/golang-gin-realworld-example-app/internal/session/usecase"
 	userHttp "github.com/gothinkster/golang-gin-realworld-example-app/internal/user/delivery/http"
-	userRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/user/repository"
+	userUsecase "github.com/gothinkster/golang-gin-realworld-example-app/internal/user/usecase"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/httpErrors"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/metric"
 )
 
 func (s *Server) MapHandlers(engine *gin.Engine) error {
+	if err := httpErrors.ValidatorTranslationsInit(); err != nil {
+		return err
+	}
 
 	// resources
-	articleRepo := articleRepository.NewArticleRepository(s.db)
-	articleUc := articleUsecase.NewArticleUseCase(articleRepo)
-	articleHandlers := articleHttp.NewArticleHandlers(articleRepo, articleUc, s.locker)
-	sessRepo := sessRepository.NewSessionRepository(s.cfg, s.redisClient)
-	sessUC := sessUsecase.NewSessionUseCase(s.cfg, sessRepo)
-	userRepo := userRepository.NewUserRepository(s.db)
-	userHandler := userHttp.NewUserHandlers(s.cfg, userRepo, sessUC, s.locker)
-	mv := middleware.NewMiddlewareManager(s.db, sessUC, []string{"*"})
+	articleUc := articleUsecase.NewArticleUseCase(s.storage.articleRepo, s.storage.txManager)
+	articleHandlers := articleHttp.NewArticleHandlers(s.cfg, s.storage.articleRepo, articleUc, s.locker)
+	sessUC := sessUsecase.NewSessionUseCase(s.cfg, s.storage.sessRepo)
+	userUC := userUsecase.NewUserUseCase(s.cfg, s.storage.userRepo, s.storage.userRedisRepo, sessUC, s.mailer, s.oauthClient, s.storage.txManager)
+	userHandler := userHttp.NewUserHandlers(s.cfg, s.storage.userRepo, userUC, sessUC, s.locker)
+	mv := middleware.NewMiddlewareManager(s.cfg, s.storage.userRepo, sessUC, userUC, s.storage.limiter, s.cfg.Cors.Origins, s.logger, s.locker, s.storage.idempotencyStore)
 
 	// Middlewares
 	{
 		p := metric.NewPrometheus("gin")
-		p.Use(engine)
 		engine.Use(mv.MetricsMiddleware(p))
 		//recovery middleware
 		engine.Use(gin.Recovery())
 		//middleware which injects a 'RequestID' into the context and header of each request.
 		engine.Use(requestid.RequestID(nil))
-		//middleware which enhance Gin request logger to include 'RequestID'
-		engine.Use(gin.LoggerWithConfig(requestid.GetLoggerConfig(nil, nil, nil)))
+		//middleware which starts the server span of the request, continuing the trace of the client
+		engine.Use(mv.TracingMiddleware())
+		//middleware which attaches a request scoped logger including 'RequestID' and writes the access log
+		engine.Use(mv.RequestLoggerMiddleware())
+		//middleware which renders the errors handlers add with c.Error
+		engine.Use(mv.ErrorMiddleware())
+		engine.Use(mv.CorsMiddleware())
+		//middleware which sets the request deadline repositories stop at
+		engine.Use(mv.TimeoutMiddleware())
 	}
 
 	// routes
 	{
 		v1 := engine.Group("/api")
 		// public routes
-		v1.Use(mv.AuthMiddleware(s.db, false))
-		userHttp.UsersRegister(v1.Group("/users"), userHandler)
-		articleHttp.ArticlesAnonymousRouteRegister(v1.Group("/articles"), articleHandlers)
-		articleHttp.TagsAnonymousRouteRegister(v1.Group("/tags"), articleHandlers)
+		v1.Use(mv.AuthMiddleware(false))
+		userHttp.UsersRegister(v1.Group("/users", mv.RateLimitMiddleware("users")), userHandler, mv.IdempotencyMiddleware())
+		articleHttp.ArticlesAnonymousRouteRegister(v1.Group("/articles", mv.ScopeMiddleware(models.ScopeReadArticles)), articleHandlers)
+		articleHttp.TagsAnonymousRouteRegister(v1.Group("/tags", mv.ScopeMiddleware(models.ScopeReadArticles)), articleHandlers)
+
+		// protected routes, personal access tokens need the scope of the group
+		v1.Use(mv.AuthMiddleware(true))
+		articleHttp.ArticlesRouteRegister(v1.Group("/articles", mv.ScopeMiddleware(models.ScopeWriteArticles), mv.RateLimitMiddleware("articles")), articleHandlers, mv.IdempotencyMiddleware())
+		userHttp.UserRegister(v1.Group("/user", mv.ScopeMiddleware(models.ScopeManageProfile)), userHandler)
+		userHttp.ProfileRegister(v1.Group("/profiles", mv.ScopeMiddleware(models.ScopeManageProfile)), userHandler)
+
+		// session only routes
+		userHttp.UserSecurityRegister(v1.Group("/user", mv.SessionOnlyMiddleware()), userHandler)
 
-		// protected routes
-		v1.Use(mv.AuthMiddleware(s.db, true))
-		articleHttp.ArticlesRouteRegister(v1.Group("/articles"), articleHandlers)
-		userHttp.UserRegister(v1.Group("/user"), userHandler)
-		userHttp.ProfileRegister(v1.Group("/profiles"), userHandler)
+		// admin routes
+		admin := v1.Group("/admin", mv.SessionOnlyMiddleware())
+		admin.Use(mv.RoleMiddleware(models.RoleAdmin))
+		userHttp.AdminUserRegister(admin.Group("/users"), userHandler)
 
 		engine.GET("/healthz", func(c *gin.Context) {
 			c.Status(http.StatusOK)
 		})
+		engine.GET("/livez", s.health.LivezHandler())
+		engine.GET("/readyz", s.health.ReadyzHandler())
 	}
 
 	return nil
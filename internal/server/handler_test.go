@@ -0,0 +1,639 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/health"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/mailer"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/oauth"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// format of createdAt and updatedAt in responses
+const timestampLayout = "2006-01-02T15:04:05.999Z"
+
+type testUser struct {
+	Username string  `json:"username"`
+	Email    string  `json:"email"`
+	Bio      string  `json:"bio"`
+	Image    *string `json:"image"`
+	Token    string  `json:"token"`
+}
+
+type testProfile struct {
+	Username  string  `json:"username"`
+	Bio       string  `json:"bio"`
+	Image     *string `json:"image"`
+	Following bool    `json:"following"`
+}
+
+type testArticle struct {
+	Slug           string      `json:"slug"`
+	Title          string      `json:"title"`
+	Description    string      `json:"description"`
+	Body           string      `json:"body"`
+	TagList        []string    `json:"tagList"`
+	CreatedAt      string      `json:"createdAt"`
+	UpdatedAt      string      `json:"updatedAt"`
+	Favorited      bool        `json:"favorited"`
+	FavoritesCount int         `json:"favoritesCount"`
+	Author         testProfile `json:"author"`
+}
+
+type testComment struct {
+	ID        uint        `json:"id"`
+	Body      string      `json:"body"`
+	CreatedAt string      `json:"createdAt"`
+	UpdatedAt string      `json:"updatedAt"`
+	Author    testProfile `json:"author"`
+}
+
+type testAccessToken struct {
+	Token string `json:"token"`
+}
+
+type testResponse struct {
+	User          testUser               `json:"user"`
+	Profile       testProfile            `json:"profile"`
+	Article       testArticle            `json:"article"`
+	Articles      []testArticle          `json:"articles"`
+	ArticlesCount int                    `json:"articlesCount"`
+	Comment       testComment            `json:"comment"`
+	Comments      []testComment          `json:"comments"`
+	Tags          []string               `json:"tags"`
+	Token         testAccessToken        `json:"token"`
+	Errors        map[string]interface{} `json:"errors"`
+}
+
+// The api served from memory, no postgres or redis needed
+type testAPI struct {
+	t       *testing.T
+	server  *Server
+	handler http.Handler
+	admin   http.Handler
+}
+
+func newTestAPI(t *testing.T, configure func(cfg *config.Config)) *testAPI {
+	v, err := config.LoadConfig("../../config/config-local")
+	require.NoError(t, err)
+	cfg, err := config.ParseConfig(v)
+	require.NoError(t, err)
+	cfg.Server.Debug = false
+	cfg.Logger.Level = "fatal"
+	cfg.RateLimit.Policies = nil
+	if configure != nil {
+		configure(cfg)
+	}
+
+	appLogger := logger.NewApiLogger(cfg)
+	appLogger.InitLogger()
+	s := NewMemoryServer(cfg, mailer.MailerInit(cfg), oauth.OAuthInit(cfg), appLogger)
+	require.NoError(t, s.MapHandlers(s.gin))
+	admin := gin.New()
+	s.MapAdminHandlers(admin)
+	return &testAPI{t: t, server: s, handler: s.gin, admin: admin}
+}
+
+// headers are pairs of names and values
+func (a *testAPI) do(method, path, token string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		require.NoError(a.t, json.NewEncoder(&reader).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// Request to the admin server, headers are pairs of names and values
+func (a *testAPI) doAdmin(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.admin.ServeHTTP(rec, req)
+	return rec
+}
+
+// Send the request, check the status and decode the body
+func (a *testAPI) expect(status int, method, path, token string, body interface{}, headers ...string) testResponse {
+	a.t.Helper()
+	rec := a.do(method, path, token, body, headers...)
+	require.Equal(a.t, status, rec.Code, "%s %s: %s", method, path, rec.Body.String())
+	var response testResponse
+	if rec.Body.Len() > 0 {
+		require.NoError(a.t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+	}
+	return response
+}
+
+func (a *testAPI) register(username, email, password string) testUser {
+	a.t.Helper()
+	return a.expect(http.StatusCreated, http.MethodPost, "/api/users/", "", userBody(username, email, password)).User
+}
+
+func (a *testAPI) createArticle(token, title string, tags ...string) testArticle {
+	a.t.Helper()
+	return a.expect(http.StatusCreated, http.MethodPost, "/api/articles/", token, articleBody(title, tags...)).Article
+}
+
+func userBody(username, email, password string) map[string]interface{} {
+	return map[string]interface{}{"user": map[string]string{"username": username, "email": email, "password": password}}
+}
+
+func articleBody(title string, tags ...string) map[string]interface{} {
+	return map[string]interface{}{"article": map[string]interface{}{
+		"title":       title,
+		"description": "Ever wonder how?",
+		"body":        "You have to believe",
+		"tagList":     tags,
+	}}
+}
+
+func assertTimestamp(t *testing.T, value string) {
+	t.Helper()
+	_, err := time.Parse(timestampLayout, value)
+	assert.NoError(t, err, "timestamp %q", value)
+}
+
+// The scenarios of the RealWorld Postman collection, in its order
+func TestRealWorldAPI(t *testing.T) {
+	api := newTestAPI(t, nil)
+	const (
+		username = "johnjacob"
+		email    = "john@jacob.com"
+		password = "johnnyjacob"
+	)
+	var token string
+
+	t.Run("Auth", func(t *testing.T) {
+		user := api.register(username, email, password)
+		assert.Equal(t, username, user.Username)
+		assert.Equal(t, email, user.Email)
+		assert.Equal(t, "", user.Bio)
+		assert.Nil(t, user.Image)
+		assert.NotEmpty(t, user.Token)
+
+		user = api.expect(http.StatusOK, http.MethodPost, "/api/users/login", "",
+			map[string]interface{}{"user": map[string]string{"email": email, "password": password}}).User
+		assert.Equal(t, username, user.Username)
+		assert.NotEmpty(t, user.Token)
+		token = user.Token
+
+		user = api.expect(http.StatusOK, http.MethodGet, "/api/user/", token, nil).User
+		assert.Equal(t, email, user.Email)
+		assert.Equal(t, token, user.Token)
+
+		image := "https://i.stack.imgur.com/xHWG8.jpg"
+		user = api.expect(http.StatusOK, http.MethodPut, "/api/user/", token,
+			map[string]interface{}{"user": map[string]string{"bio": "I like to skateboard", "image": image}}).User
+		assert.Equal(t, email, user.Email)
+		assert.Equal(t, "I like to skateboard", user.Bio)
+		require.NotNil(t, user.Image)
+		assert.Equal(t, image, *user.Image)
+
+		user = api.expect(http.StatusOK, http.MethodGet, "/api/user/", token, nil).User
+		assert.Equal(t, "I like to skateboard", user.Bio)
+	})
+
+	var slug string
+	t.Run("Articles", func(t *testing.T) {
+		response := api.expect(http.StatusOK, http.MethodGet, "/api/articles/", "", nil)
+		assert.Empty(t, response.Articles)
+		assert.Equal(t, 0, response.ArticlesCount)
+
+		article := api.createArticle(token, "How to train your dragon", "training", "dragons")
+		slug = article.Slug
+		assert.Equal(t, "how-to-train-your-dragon", article.Slug)
+		assert.Equal(t, "How to train your dragon", article.Title)
+		assert.Equal(t, "Ever wonder how?", article.Description)
+		assert.Equal(t, "You have to believe", article.Body)
+		assert.Equal(t, []string{"dragons", "training"}, article.TagList)
+		assert.False(t, article.Favorited)
+		assert.Equal(t, 0, article.FavoritesCount)
+		assert.Equal(t, username, article.Author.Username)
+		assertTimestamp(t, article.CreatedAt)
+		assertTimestamp(t, article.UpdatedAt)
+
+		for _, path := range []string{"/api/articles/", "/api/articles/?author=" + username, "/api/articles/?tag=dragons"} {
+			for _, tok := range []string{"", token} {
+				response = api.expect(http.StatusOK, http.MethodGet, path, tok, nil)
+				require.Len(t, response.Articles, 1, path)
+				assert.Equal(t, 1, response.ArticlesCount, path)
+				assert.Equal(t, slug, response.Articles[0].Slug, path)
+			}
+		}
+		response = api.expect(http.StatusOK, http.MethodGet, "/api/articles/?tag=unknown", "", nil)
+		assert.Empty(t, response.Articles)
+		response = api.expect(http.StatusOK, http.MethodGet, "/api/articles/?favorited="+username, token, nil)
+		assert.Empty(t, response.Articles)
+		assert.Equal(t, 0, response.ArticlesCount)
+
+		article = api.expect(http.StatusOK, http.MethodGet, "/api/articles/"+slug, "", nil).Article
+		assert.Equal(t, "How to train your dragon", article.Title)
+		assert.Equal(t, []string{"dragons", "training"}, article.TagList)
+
+		article = api.expect(http.StatusOK, http.MethodPut, "/api/articles/"+slug, token,
+			map[string]interface{}{"article": map[string]string{"body": "With two hands"}}).Article
+		assert.Equal(t, "With two hands", article.Body)
+		assert.Equal(t, "How to train your dragon", article.Title)
+
+		article = api.expect(http.StatusOK, http.MethodPost, "/api/articles/"+slug+"/favorite", token, nil).Article
+		assert.True(t, article.Favorited)
+		assert.Equal(t, 1, article.FavoritesCount)
+
+		response = api.expect(http.StatusOK, http.MethodGet, "/api/articles/?favorited="+username, token, nil)
+		require.Len(t, response.Articles, 1)
+		assert.Equal(t, 1, response.ArticlesCount)
+		assert.True(t, response.Articles[0].Favorited)
+		assert.Equal(t, 1, response.Articles[0].FavoritesCount)
+
+		article = api.expect(http.StatusOK, http.MethodDelete, "/api/articles/"+slug+"/favorite", token, nil).Article
+		assert.False(t, article.Favorited)
+		assert.Equal(t, 0, article.FavoritesCount)
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		comment := api.expect(http.StatusCreated, http.MethodPost, "/api/articles/"+slug+"/comments", token,
+			map[string]interface{}{"comment": map[string]string{"body": "Thank you so much!"}}).Comment
+		assert.NotZero(t, comment.ID)
+		assert.Equal(t, "Thank you so much!", comment.Body)
+		assert.Equal(t, username, comment.Author.Username)
+		assertTimestamp(t, comment.CreatedAt)
+		assertTimestamp(t, comment.UpdatedAt)
+
+		comments := api.expect(http.StatusOK, http.MethodGet, "/api/articles/"+slug+"/comments", "", nil).Comments
+		require.Len(t, comments, 1)
+		assert.Equal(t, comment.ID, comments[0].ID)
+		assert.Equal(t, username, comments[0].Author.Username)
+
+		rec := api.do(http.MethodDelete, "/api/articles/"+slug+"/comments/"+jsonNumber(comment.ID), token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		comments = api.expect(http.StatusOK, http.MethodGet, "/api/articles/"+slug+"/comments", token, nil).Comments
+		assert.Empty(t, comments)
+	})
+
+	t.Run("Profiles", func(t *testing.T) {
+		celeb := api.register("celeb_"+username, "celeb_"+email, password)
+		api.createArticle(celeb.Token, "Dragons of the celebrities", "dragons")
+
+		profile := api.expect(http.StatusOK, http.MethodGet, "/api/profiles/celeb_"+username, token, nil).Profile
+		assert.Equal(t, "celeb_"+username, profile.Username)
+		assert.False(t, profile.Following)
+
+		feed := api.expect(http.StatusOK, http.MethodGet, "/api/articles/feed", token, nil)
+		assert.Empty(t, feed.Articles)
+		assert.Equal(t, 0, feed.ArticlesCount)
+
+		profile = api.expect(http.StatusOK, http.MethodPost, "/api/profiles/celeb_"+username+"/follow", token, nil).Profile
+		assert.True(t, profile.Following)
+		profile = api.expect(http.StatusOK, http.MethodGet, "/api/profiles/celeb_"+username, token, nil).Profile
+		assert.True(t, profile.Following)
+
+		feed = api.expect(http.StatusOK, http.MethodGet, "/api/articles/feed", token, nil)
+		require.Len(t, feed.Articles, 1)
+		assert.Equal(t, 1, feed.ArticlesCount)
+		assert.Equal(t, "dragons-of-the-celebrities", feed.Articles[0].Slug)
+
+		profile = api.expect(http.StatusOK, http.MethodDelete, "/api/profiles/celeb_"+username+"/follow", token, nil).Profile
+		assert.False(t, profile.Following)
+		feed = api.expect(http.StatusOK, http.MethodGet, "/api/articles/feed", token, nil)
+		assert.Empty(t, feed.Articles)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		tags := api.expect(http.StatusOK, http.MethodGet, "/api/tags/", "", nil).Tags
+		assert.ElementsMatch(t, []string{"training", "dragons"}, tags)
+	})
+
+	t.Run("Delete article", func(t *testing.T) {
+		rec := api.do(http.MethodDelete, "/api/articles/"+slug, token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		api.expect(http.StatusNotFound, http.MethodGet, "/api/articles/"+slug, "", nil)
+		response := api.expect(http.StatusOK, http.MethodGet, "/api/articles/?author="+username, "", nil)
+		assert.Empty(t, response.Articles)
+		assert.Equal(t, 0, response.ArticlesCount)
+	})
+}
+
+func TestAuthErrors(t *testing.T) {
+	api := newTestAPI(t, nil)
+	jake := api.register("jake", "jake@jake.jake", "jakejakejake")
+
+	response := api.expect(http.StatusUnprocessableEntity, http.MethodPost, "/api/users/", "",
+		map[string]interface{}{"user": map[string]string{"username": "jacob", "password": "jakejakejake"}})
+	assert.Contains(t, response.Errors, "email")
+
+	api.expect(http.StatusConflict, http.MethodPost, "/api/users/", "", userBody("jake2", "jake@jake.jake", "jakejakejake"))
+	api.expect(http.StatusForbidden, http.MethodPost, "/api/users/login", "",
+		map[string]interface{}{"user": map[string]string{"email": "jake@jake.jake", "password": "wrong password"}})
+	api.expect(http.StatusForbidden, http.MethodPost, "/api/users/login", "",
+		map[string]interface{}{"user": map[string]string{"email": "nobody@jake.jake", "password": "jakejakejake"}})
+
+	api.expect(http.StatusUnauthorized, http.MethodGet, "/api/user/", "", nil)
+	api.expect(http.StatusUnauthorized, http.MethodGet, "/api/user/", "not-a-token", nil)
+	api.expect(http.StatusUnauthorized, http.MethodGet, "/api/user/", utils.GenToken(1, "session", "not the configured secret"), nil)
+	api.expect(http.StatusUnauthorized, http.MethodGet, "/api/articles/feed", "", nil)
+	api.expect(http.StatusNotFound, http.MethodGet, "/api/profiles/nobody", jake.Token, nil)
+
+	// passwords only change through the password routes
+	response = api.expect(http.StatusUnprocessableEntity, http.MethodPut, "/api/user/", jake.Token,
+		map[string]interface{}{"user": map[string]string{"password": "a new password"}})
+	assert.Contains(t, response.Errors, "password")
+	api.expect(http.StatusOK, http.MethodPost, "/api/users/login", "",
+		map[string]interface{}{"user": map[string]string{"email": "jake@jake.jake", "password": "jakejakejake"}})
+
+	// a personal access token edits the profile but not the email, which resets the password
+	pat := api.expect(http.StatusCreated, http.MethodPost, "/api/user/tokens", jake.Token,
+		map[string]interface{}{"token": map[string]interface{}{"name": "cli", "scopes": []string{"profile:manage"}}}).Token.Token
+	response = api.expect(http.StatusOK, http.MethodPut, "/api/user/", pat,
+		map[string]interface{}{"user": map[string]string{"bio": "I work at statefarm"}})
+	assert.Equal(t, "I work at statefarm", response.User.Bio)
+	response = api.expect(http.StatusForbidden, http.MethodPut, "/api/user/", pat,
+		map[string]interface{}{"user": map[string]string{"email": "attacker@jake.jake"}})
+	assert.Contains(t, response.Errors, "email")
+}
+
+func TestAccountUnlock(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.LoginThrottle.MaxAttempts = 10
+		cfg.LoginThrottle.MaxIPAttempts = 2
+		cfg.LoginThrottle.BaseDelay = 0
+	})
+	admin := api.register("admin", "admin@example.com", "password123")
+	adminModel, err := api.server.storage.userRepo.FindOneUser(context.Background(), &models.User{Username: "admin"})
+	require.NoError(t, err)
+	require.NoError(t, api.server.storage.userRepo.Update(context.Background(), models.User{ID: adminModel.ID, Role: models.RoleAdmin}))
+	api.register("jake", "jake@jake.jake", "jakejakejake")
+
+	wrong := map[string]interface{}{"user": map[string]string{"email": "jake@jake.jake", "password": "wrong password"}}
+	right := map[string]interface{}{"user": map[string]string{"email": "jake@jake.jake", "password": "jakejakejake"}}
+	api.expect(http.StatusForbidden, http.MethodPost, "/api/users/login", "", wrong)
+	api.expect(http.StatusTooManyRequests, http.MethodPost, "/api/users/login", "", wrong)
+	api.expect(http.StatusTooManyRequests, http.MethodPost, "/api/users/login", "", right)
+
+	// the address jake was locked out from is unlocked with the account
+	require.Equal(t, http.StatusOK, api.do(http.MethodDelete, "/api/admin/users/jake/lockout", admin.Token, nil).Code)
+	api.expect(http.StatusOK, http.MethodPost, "/api/users/login", "", right)
+}
+
+func TestArticleErrors(t *testing.T) {
+	api := newTestAPI(t, nil)
+	author := api.register("author", "author@example.com", "password123")
+	other := api.register("other", "other@example.com", "password123")
+	article := api.createArticle(author.Token, "An article of the author")
+
+	api.expect(http.StatusUnauthorized, http.MethodPost, "/api/articles/", "", articleBody("Anonymous article"))
+	api.expect(http.StatusUnprocessableEntity, http.MethodPost, "/api/articles/", author.Token, articleBody("abc"))
+	api.expect(http.StatusConflict, http.MethodPost, "/api/articles/", other.Token, articleBody("An article of the author"))
+
+	api.expect(http.StatusNotFound, http.MethodGet, "/api/articles/missing", "", nil)
+	api.expect(http.StatusNotFound, http.MethodGet, "/api/articles/missing/comments", "", nil)
+	api.expect(http.StatusNotFound, http.MethodPost, "/api/articles/missing/favorite", other.Token, nil)
+
+	api.expect(http.StatusForbidden, http.MethodPut, "/api/articles/"+article.Slug, other.Token,
+		map[string]interface{}{"article": map[string]string{"body": "Not mine"}})
+	api.expect(http.StatusForbidden, http.MethodDelete, "/api/articles/"+article.Slug, other.Token, nil)
+
+	comment := api.expect(http.StatusCreated, http.MethodPost, "/api/articles/"+article.Slug+"/comments", author.Token,
+		map[string]interface{}{"comment": map[string]string{"body": "First"}}).Comment
+	api.expect(http.StatusForbidden, http.MethodDelete, "/api/articles/"+article.Slug+"/comments/"+jsonNumber(comment.ID), other.Token, nil)
+	api.expect(http.StatusNotFound, http.MethodDelete, "/api/articles/"+article.Slug+"/comments/999999", author.Token, nil)
+
+	// deleted articles don't hold on to their slug
+	require.Equal(t, http.StatusOK, api.do(http.MethodDelete, "/api/articles/"+article.Slug, author.Token, nil).Code)
+	api.expect(http.StatusNotFound, http.MethodGet, "/api/articles/"+article.Slug, "", nil)
+	recreated := api.createArticle(author.Token, "An article of the author")
+	assert.Equal(t, article.Slug, recreated.Slug)
+}
+
+func TestArticleConditionalRequests(t *testing.T) {
+	api := newTestAPI(t, nil)
+	author := api.register("author", "author@example.com", "password123")
+	article := api.createArticle(author.Token, "Versioned article")
+	path := "/api/articles/" + article.Slug
+
+	etag := api.do(http.MethodGet, path, "", nil).Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, http.StatusNotModified, api.do(http.MethodGet, path, "", nil, "If-None-Match", etag).Code)
+
+	update := map[string]interface{}{"article": map[string]string{"body": "Second version"}}
+	rec := api.do(http.MethodPut, path, author.Token, update, "If-Match", etag)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	api.expect(http.StatusPreconditionFailed, http.MethodPut, path, author.Token, update, "If-Match", etag)
+	api.expect(http.StatusPreconditionFailed, http.MethodDelete, path, author.Token, nil, "If-Match", etag)
+	assert.Equal(t, http.StatusOK, api.do(http.MethodGet, path, "", nil, "If-None-Match", etag).Code)
+
+	// the reader sees favorited set, the tag of the anonymous response isn't theirs
+	reader := api.register("reader", "reader@example.com", "password123")
+	api.expect(http.StatusOK, http.MethodPost, path+"/favorite", reader.Token, nil)
+	etag = api.do(http.MethodGet, path, "", nil).Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, api.do(http.MethodGet, path, reader.Token, nil, "If-None-Match", etag).Code)
+
+	strict := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Server.RequireIfMatch = true
+	})
+	author = strict.register("author", "author@example.com", "password123")
+	article = strict.createArticle(author.Token, "Versioned article")
+	strict.expect(http.StatusPreconditionRequired, http.MethodPut, "/api/articles/"+article.Slug, author.Token, update)
+}
+
+func TestIdempotentArticleCreate(t *testing.T) {
+	api := newTestAPI(t, nil)
+	author := api.register("author", "author@example.com", "password123")
+
+	first := api.do(http.MethodPost, "/api/articles/", author.Token, articleBody("Created once"), "Idempotency-Key", "create-1")
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+	retry := api.do(http.MethodPost, "/api/articles/", author.Token, articleBody("Created once"), "Idempotency-Key", "create-1")
+	require.Equal(t, http.StatusCreated, retry.Code, retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+	api.expect(http.StatusUnprocessableEntity, http.MethodPost, "/api/articles/", author.Token, articleBody("Something else"), "Idempotency-Key", "create-1")
+	response := api.expect(http.StatusOK, http.MethodGet, "/api/articles/", "", nil)
+	assert.Equal(t, 1, response.ArticlesCount)
+}
+
+func TestRateLimit(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.RateLimit.Policies = map[string]config.RateLimitPolicy{"users": {Rate: 1, Period: 60, Burst: 2}}
+	})
+	login := map[string]interface{}{"user": map[string]string{"email": "nobody@example.com", "password": "password123"}}
+
+	api.expect(http.StatusForbidden, http.MethodPost, "/api/users/login", "", login)
+	api.expect(http.StatusForbidden, http.MethodPost, "/api/users/login", "", login)
+	rec := api.do(http.MethodPost, "/api/users/login", "", login)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestCors(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Cors.Origins = []string{`regex:https://app\.example\.com`}
+	})
+
+	rec := api.do(http.MethodGet, "/api/tags/", "", nil, "Origin", "https://app.example.com")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// the expression has to match the whole origin
+	rec = api.do(http.MethodGet, "/api/tags/", "", nil, "Origin", "https://app.example.com.evil.io")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestTraceparent(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	rec := api.do(http.MethodGet, "/api/tags/", "", nil, "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rec.Header().Get("X-Trace-Id"))
+}
+
+func TestMetricsLabels(t *testing.T) {
+	api := newTestAPI(t, nil)
+	author := api.register("metrics", "metrics@example.com", "password123")
+	article := api.createArticle(author.Token, "Metrics label")
+	api.expect(http.StatusOK, http.MethodGet, "/api/articles/"+article.Slug, "", nil)
+	api.do(http.MethodGet, "/api/no-such-route", "", nil)
+
+	rec := api.doAdmin(http.MethodGet, "/metrics", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	metrics := rec.Body.String()
+	assert.Contains(t, metrics, `url="/api/articles/:slug"`)
+	assert.Contains(t, metrics, `url="unmatched"`)
+	assert.NotContains(t, metrics, article.Slug)
+	assert.Contains(t, metrics, `users_registrations_total{method="password"}`)
+	assert.Contains(t, metrics, "articles_published_total")
+	assert.Contains(t, metrics, "sessions_active")
+
+	// only favorites which changed something are counted
+	favorites := metricValue(t, api, `articles_favorites_total{action="favorite"}`)
+	api.expect(http.StatusOK, http.MethodPost, "/api/articles/"+article.Slug+"/favorite", author.Token, nil)
+	api.expect(http.StatusOK, http.MethodPost, "/api/articles/"+article.Slug+"/favorite", author.Token, nil)
+	assert.Equal(t, favorites+1, metricValue(t, api, `articles_favorites_total{action="favorite"}`))
+}
+
+// Value of a series of the admin server's metrics, 0 when it has none yet
+func metricValue(t *testing.T, api *testAPI, series string) float64 {
+	t.Helper()
+	rec := api.doAdmin(http.MethodGet, "/metrics", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			require.NoError(t, err, line)
+			return v
+		}
+	}
+	return 0
+}
+
+func TestHealthProbes(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Health.Timeout = 50
+	})
+	var report health.Report
+	readyz := func(status int) {
+		t.Helper()
+		rec := api.do(http.MethodGet, "/readyz", "", nil)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	}
+
+	api.expect(http.StatusOK, http.MethodGet, "/livez", "", nil)
+	readyz(http.StatusOK)
+	assert.Equal(t, health.StatusUp, report.Status)
+
+	api.server.health.RegisterOptional("exporter", health.CheckerFunc(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+	readyz(http.StatusOK)
+	assert.Equal(t, health.StatusDown, report.Components["exporter"].Status)
+
+	api.server.health.Register("postgres", health.CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	readyz(http.StatusServiceUnavailable)
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["postgres"].Error)
+
+	api.server.health.Drain()
+	readyz(http.StatusServiceUnavailable)
+	assert.Equal(t, health.StatusDraining, report.Status)
+	api.expect(http.StatusOK, http.MethodGet, "/livez", "", nil)
+}
+
+func TestAdminServer(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Metrics.Username = "ops"
+		cfg.Metrics.Password = "secret"
+		cfg.OAuth.Providers = map[string]config.OAuthProvider{"google": {ClientID: "id", ClientSecret: "client-secret"}}
+	})
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("ops:secret"))
+
+	assert.Equal(t, http.StatusUnauthorized, api.doAdmin(http.MethodGet, "/buildinfo", "").Code)
+	assert.Equal(t, http.StatusNotFound, api.do(http.MethodGet, "/metrics", "", nil).Code)
+
+	rec := api.doAdmin(http.MethodGet, "/buildinfo", "", "Authorization", auth)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"goVersion":"`+runtime.Version()+`"`)
+
+	rec = api.doAdmin(http.MethodGet, "/config", "", "Authorization", auth)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var dump struct {
+		Server  map[string]interface{}
+		Metrics map[string]interface{}
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &dump))
+	assert.Equal(t, "[redacted]", dump.Server["JwtSecretKey"])
+	assert.Equal(t, "[redacted]", dump.Metrics["Password"])
+	assert.Equal(t, api.server.cfg.Server.AppName, dump.Server["AppName"])
+	assert.NotContains(t, rec.Body.String(), "client-secret")
+
+	rec = api.doAdmin(http.MethodPut, "/loglevel", `{"level":"debug"}`, "Authorization", auth)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, zapcore.DebugLevel, logger.Level().Level())
+	logger.Level().SetLevel(zapcore.FatalLevel)
+
+	rec = api.doAdmin(http.MethodGet, "/debug/pprof/goroutine?debug=1", "", "Authorization", auth)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine profile")
+}
+
+func jsonNumber(id uint) string {
+	b, _ := json.Marshal(id)
+	return string(b)
+}
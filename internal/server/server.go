@@ -2,7 +2,6 @@ package server
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,7 +12,12 @@ import (
 	"github.com/gothinkster/golang-gin-realworld-example-app/config"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/redis"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/health"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/locker"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/mailer"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/oauth"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
 )
 
 const (
@@ -23,15 +27,43 @@ const (
 
 type Server struct {
 	gin         *gin.Engine
-	db          *postgres.DB
-	redisClient *redis.Client
+	storage     *storage
+	health      *health.Registry
 	locker      locker.Locker
+	mailer      mailer.Mailer
+	oauthClient oauth.Client
 	cfg         *config.Config
-	// logger      logger.Logger
+	logger      logger.Logger
 }
 
 // NewServer New Server constructor
-func NewServer(cfg *config.Config, db *postgres.DB, redisClient *redis.Client, locker locker.Locker) *Server {
+func NewServer(cfg *config.Config, db *postgres.DB, redisClient *redis.Client, locker locker.Locker, mailer mailer.Mailer, oauthClient oauth.Client, logger logger.Logger) *Server {
+	setGinMode(cfg)
+	healthRegistry := newHealthRegistry(cfg)
+	healthRegistry.Register("postgres", health.CheckerFunc(func(ctx context.Context) error {
+		return db.DB().PingContext(ctx)
+	}))
+	healthRegistry.Register("redis", health.CheckerFunc(func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	}))
+	if cfg.Tracing.Exporter == tracing.ExporterOTLP {
+		healthRegistry.RegisterOptional("tracing", health.DialChecker(cfg.Tracing.Endpoint))
+	}
+	return &Server{gin: gin.New(), cfg: cfg, storage: newStorage(cfg, db, redisClient), health: healthRegistry, locker: locker, mailer: mailer, oauthClient: oauthClient, logger: logger}
+}
+
+// Server which keeps users, articles, sessions and locks in memory instead of
+// postgres and redis, for tests and trying the api out without any services
+func NewMemoryServer(cfg *config.Config, mailer mailer.Mailer, oauthClient oauth.Client, logger logger.Logger) *Server {
+	setGinMode(cfg)
+	return &Server{gin: gin.New(), cfg: cfg, storage: newMemoryStorage(cfg), health: newHealthRegistry(cfg), locker: locker.NewMemoryLocker(cfg), mailer: mailer, oauthClient: oauthClient, logger: logger}
+}
+
+func newHealthRegistry(cfg *config.Config) *health.Registry {
+	return health.NewRegistry(time.Duration(cfg.Health.Timeout)*time.Millisecond, time.Duration(cfg.Health.CacheTTL)*time.Millisecond)
+}
+
+func setGinMode(cfg *config.Config) {
 	var serverMode string
 	if cfg.Server.Debug {
 		serverMode = gin.DebugMode
@@ -39,7 +71,6 @@ func NewServer(cfg *config.Config, db *postgres.DB, redisClient *redis.Client, l
 		serverMode = gin.ReleaseMode
 	}
 	gin.SetMode(serverMode)
-	return &Server{gin: gin.Default(), cfg: cfg, db: db, redisClient: redisClient, locker: locker}
 }
 
 func (s *Server) Run() error {
@@ -59,16 +90,47 @@ func (s *Server) Run() error {
 	go func() {
 		// service connections
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			s.logger.Fatalf("listen: %s", err)
 		}
 	}()
 
+	// admin server, an empty metrics url disables it
+	var adminSrv *http.Server
+	if s.cfg.Metrics.URL != "" {
+		adminEngine := gin.New()
+		s.MapAdminHandlers(adminEngine)
+		// no write timeout, cpu profiles and traces take as long as they're asked to
+		adminSrv = &http.Server{
+			Addr:              s.cfg.Metrics.URL,
+			Handler:           adminEngine.Handler(),
+			ReadHeaderTimeout: time.Second * s.cfg.Server.ReadTimeout,
+			MaxHeaderBytes:    maxHeaderBytes,
+		}
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				s.logger.Fatalf("admin listen: %s", err)
+			}
+		}()
+		s.logger.Infof("Admin server listening on %s", s.cfg.Metrics.URL)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutdown Server ...")
+	// keep serving while load balancers see the instance isn't ready anymore
+	s.health.Drain()
+	s.logger.Infof("Draining for %d seconds ...", s.cfg.Health.DrainDelay)
+	time.Sleep(time.Duration(s.cfg.Health.DrainDelay) * time.Second)
+	s.logger.Info("Shutdown Server ...")
 
 	ctx, shutdown := context.WithTimeout(context.Background(), ctxTimeout*time.Second)
 	defer shutdown()
-	return srv.Shutdown(ctx)
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	// the admin server goes last so the drain stays observable
+	if adminSrv != nil {
+		return adminSrv.Close()
+	}
+	return nil
 }
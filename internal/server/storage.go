@@ -0,0 +1,54 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/article"
+	articleRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/article/repository"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
+	sessRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/session/repository"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	userRepository "github.com/gothinkster/golang-gin-realworld-example-app/internal/user/repository"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/redis"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/idempotency"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/ratelimit"
+)
+
+// Repositories and stores the handlers are built on
+type storage struct {
+	txManager        postgres.TxManager
+	articleRepo      article.Repository
+	userRepo         user.Repository
+	userRedisRepo    user.RedisRepository
+	sessRepo         session.SessRepository
+	limiter          ratelimit.Limiter
+	idempotencyStore idempotency.Store
+}
+
+func newStorage(cfg *config.Config, db *postgres.DB, redisClient *redis.Client) *storage {
+	return &storage{
+		txManager:        postgres.NewTxManager(db),
+		articleRepo:      articleRepository.NewArticleRepository(db),
+		userRepo:         userRepository.NewUserRepository(db),
+		userRedisRepo:    userRepository.NewUserRedisRepository(cfg, redisClient),
+		sessRepo:         sessRepository.NewSessionRepository(cfg, redisClient),
+		limiter:          ratelimit.RateLimiterInit(redisClient, cfg),
+		idempotencyStore: idempotency.IdempotencyStoreInit(redisClient, cfg),
+	}
+}
+
+// Everything kept in the memory of the process, nothing survives a restart
+func newMemoryStorage(cfg *config.Config) *storage {
+	userRepo := userRepository.NewUserMemoryRepository()
+	return &storage{
+		txManager:        postgres.NewMemoryTxManager(),
+		articleRepo:      articleRepository.NewArticleMemoryRepository(userRepo),
+		userRepo:         userRepo,
+		userRedisRepo:    userRepository.NewUserMemoryRedisRepository(),
+		sessRepo:         sessRepository.NewSessionMemoryRepository(),
+		limiter:          ratelimit.NewMemoryLimiter(),
+		idempotencyStore: idempotency.NewMemoryStore(time.Duration(cfg.Idempotency.Expire) * time.Second),
+	}
+}
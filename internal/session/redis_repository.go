@@ -9,7 +9,9 @@ import (
 
 // Session repository
 type SessRepository interface {
-	CreateSession(ctx context.Context, sessionID string, jwt string, expire int) (*models.Session, error)
+	CreateSession(ctx context.Context, sessionID string, userID uint, jwt string, expire int) (*models.Session, error)
 	GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error)
 	DeleteByID(ctx context.Context, sessionID string) error
+	DeleteByUserID(ctx context.Context, userID uint, keepSessionID string) error
+	CountActive(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
+	"github.com/pkg/errors"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Session repository of a single process, for running without redis
+type sessionMemoryRepo struct {
+	mu       sync.Mutex
+	sessions map[string]sessionEntry
+}
+
+type sessionEntry struct {
+	session   models.Session
+	expiresAt time.Time
+}
+
+// Session memory repository constructor
+func NewSessionMemoryRepository() session.SessRepository {
+	return &sessionMemoryRepo{sessions: make(map[string]sessionEntry)}
+}
+
+func (s *sessionMemoryRepo) CreateSession(ctx context.Context, sessionID string, userID uint, jwt string, expire int) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess := models.Session{SessionID: sessionID, UserID: userID, Token: jwt}
+	s.sessions[sessionID] = sessionEntry{session: sess, expiresAt: time.Now().Add(time.Second * time.Duration(expire))}
+	return &sess, nil
+}
+
+// Missing and expired sessions are redis.Nil, like the redis repository
+func (s *sessionMemoryRepo) GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.sessions[sessionID]
+	if !ok || !entry.expiresAt.After(time.Now()) {
+		delete(s.sessions, sessionID)
+		return nil, errors.Wrap(goredis.Nil, "sessionMemoryRepo.GetSessionByID")
+	}
+	sess := entry.session
+	return &sess, nil
+}
+
+func (s *sessionMemoryRepo) DeleteByID(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *sessionMemoryRepo) DeleteByUserID(ctx context.Context, userID uint, keepSessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sessionID, entry := range s.sessions {
+		if entry.session.UserID == userID && sessionID != keepSessionID {
+			delete(s.sessions, sessionID)
+		}
+	}
+	return nil
+}
+
+func (s *sessionMemoryRepo) CountActive(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	now := time.Now()
+	for sessionID, entry := range s.sessions {
+		if entry.expiresAt.After(now) {
+			count++
+		} else {
+			delete(s.sessions, sessionID)
+		}
+	}
+	return count, nil
+}
@@ -4,14 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/gothinkster/golang-gin-realworld-example-app/config"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/redis"
-	"github.com/opentracing/opentracing-go"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
 	"github.com/pkg/errors"
+	goredis "github.com/redis/go-redis/v9"
 )
 
 // Session repository
@@ -27,20 +30,30 @@ func NewSessionRepository(cfg *config.Config, redisClient *redis.Client) session
 }
 
 // Create session in redis
-func (s *sessionRepo) CreateSession(ctx context.Context, sessionID string, jwt string, expire int) (*models.Session, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "session.sessionRepo.CreateSession")
-	defer span.Finish()
+func (s *sessionRepo) CreateSession(ctx context.Context, sessionID string, userID uint, jwt string, expire int) (*models.Session, error) {
+	ctx, span := tracing.Start(ctx, "session.sessionRepo.CreateSession")
+	defer span.End()
 
 	var sess models.Session
 	sess.SessionID = sessionID
+	sess.UserID = userID
 	sess.Token = jwt
 	sessionKey := s.buildKey(sess.SessionID)
+	userKey := s.buildUserKey(userID)
+	ttl := time.Second * time.Duration(expire)
 
 	sessBytes, err := json.Marshal(&sess)
 	if err != nil {
 		return nil, errors.WithMessage(err, "sessionRepo.CreateSession.json.Marshal")
 	}
-	if err = s.redisClient.Set(ctx, sessionKey, sessBytes, time.Second*time.Duration(expire)).Err(); err != nil {
+
+	// keep an index of the user's sessions so they can be revoked together
+	pipe := s.redisClient.TxPipeline()
+	pipe.Set(ctx, sessionKey, sessBytes, ttl)
+	pipe.SAdd(ctx, userKey, sess.SessionID)
+	pipe.Expire(ctx, userKey, ttl)
+	pipe.ZAdd(ctx, s.buildActiveKey(), goredis.Z{Score: float64(time.Now().Add(ttl).Unix()), Member: sess.SessionID})
+	if _, err = pipe.Exec(ctx); err != nil {
 		return nil, errors.Wrap(err, "sessionRepo.CreateSession.redisClient.Set")
 	}
 	return &sess, nil
@@ -48,8 +61,8 @@ func (s *sessionRepo) CreateSession(ctx context.Context, sessionID string, jwt s
 
 // Get session by id
 func (s *sessionRepo) GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "session.sessionRepo.GetSessionByID")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "session.sessionRepo.GetSessionByID")
+	defer span.End()
 
 	sessBytes, err := s.redisClient.Get(ctx, s.buildKey(sessionID)).Bytes()
 	if err != nil {
@@ -65,15 +78,78 @@ func (s *sessionRepo) GetSessionByID(ctx context.Context, sessionID string) (*mo
 
 // Delete session by id
 func (s *sessionRepo) DeleteByID(ctx context.Context, sessionID string) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "session.sessionRepo.DeleteByID")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "session.sessionRepo.DeleteByID")
+	defer span.End()
+
+	sess, err := s.GetSessionByID(ctx, sessionID)
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return errors.Wrap(err, "sessionRepo.DeleteByID")
+	}
 
-	if err := s.redisClient.Del(ctx, s.buildKey(sessionID)).Err(); err != nil {
+	pipe := s.redisClient.TxPipeline()
+	pipe.Del(ctx, s.buildKey(sessionID))
+	pipe.ZRem(ctx, s.buildActiveKey(), sessionID)
+	if sess != nil {
+		pipe.SRem(ctx, s.buildUserKey(sess.UserID), sessionID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
 		return errors.Wrap(err, "sessionRepo.DeleteByID")
 	}
 	return nil
 }
 
+// Delete all sessions of a user except keepSessionID, an empty keepSessionID deletes all of them
+func (s *sessionRepo) DeleteByUserID(ctx context.Context, userID uint, keepSessionID string) error {
+	ctx, span := tracing.Start(ctx, "session.sessionRepo.DeleteByUserID")
+	defer span.End()
+
+	userKey := s.buildUserKey(userID)
+	sessionIDs, err := s.redisClient.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return errors.Wrap(err, "sessionRepo.DeleteByUserID.redisClient.SMembers")
+	}
+
+	revoked := 0
+	pipe := s.redisClient.TxPipeline()
+	for _, sessionID := range sessionIDs {
+		if sessionID == keepSessionID {
+			continue
+		}
+		pipe.Del(ctx, s.buildKey(sessionID))
+		pipe.SRem(ctx, userKey, sessionID)
+		pipe.ZRem(ctx, s.buildActiveKey(), sessionID)
+		revoked++
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return errors.Wrap(err, "sessionRepo.DeleteByUserID.redisClient.Del")
+	}
+	logger.FromContext(ctx).Debugf("sessionRepo.DeleteByUserID UserID: %d, Revoked: %d", userID, revoked)
+	return nil
+}
+
+// Count the sessions that haven't expired, the expired ones are dropped from the index first
+func (s *sessionRepo) CountActive(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "session.sessionRepo.CountActive")
+	defer span.End()
+
+	pipe := s.redisClient.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, s.buildActiveKey(), "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	count := pipe.ZCard(ctx, s.buildActiveKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, errors.Wrap(err, "sessionRepo.CountActive.redisClient.ZCard")
+	}
+	return count.Val(), nil
+}
+
 func (s *sessionRepo) buildKey(sessionID string) string {
 	return fmt.Sprintf("%s:%s:%s", s.appName, s.basePrefix, sessionID)
 }
+
+func (s *sessionRepo) buildUserKey(userID uint) string {
+	return fmt.Sprintf("%s:%s:user:%d", s.appName, s.basePrefix, userID)
+}
+
+// sorted set of the session ids scored by their expiry
+func (s *sessionRepo) buildActiveKey() string {
+	return fmt.Sprintf("%s:%s:active", s.appName, s.basePrefix)
+}
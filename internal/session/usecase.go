@@ -12,4 +12,5 @@ type UseCase interface {
 	CreateSession(ctx context.Context, user *models.User, expire int) (*models.Session, error)
 	GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error)
 	DeleteByID(ctx context.Context, sessionID string) error
+	DeleteByUserID(ctx context.Context, userID uint, keepSessionID string) error
 }
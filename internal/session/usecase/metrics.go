@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const countActiveTimeout = 2 * time.Second
+
+// Gauge of the sessions of repo which haven't expired or been revoked, counted on
+// scrape so sessions expiring in redis aren't missed. Each server registers the one
+// of its own repository.
+func NewActiveSessionsCollector(repo session.SessRepository) prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Subsystem: "sessions",
+			Name:      "active",
+			Help:      "How many sessions haven't expired or been revoked.",
+		},
+		func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), countActiveTimeout)
+			defer cancel()
+			count, err := repo.CountActive(ctx)
+			if err != nil {
+				return 0
+			}
+			return float64(count)
+		},
+	)
+}
@@ -7,8 +7,9 @@ import (
 	"github.com/gothinkster/golang-gin-realworld-example-app/config"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
-	"github.com/opentracing/opentracing-go"
 )
 
 // Session use case
@@ -24,26 +25,43 @@ func NewSessionUseCase(cfg *config.Config, sessionRepo session.SessRepository) s
 
 // Create new session
 func (u *sessionUC) CreateSession(ctx context.Context, user *models.User, expire int) (*models.Session, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "session.sessionUC.CreateSession")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "session.sessionUC.CreateSession")
+	defer span.End()
 
 	sessionID := uuid.New().String()
 	jwt := utils.GenToken(user.ID, sessionID, u.cfg.Server.JwtSecretKey)
-	return u.sessionRepo.CreateSession(ctx, sessionID, jwt, expire)
+	sess, err := u.sessionRepo.CreateSession(ctx, sessionID, user.ID, jwt, expire)
+	if err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Debugf("session.CreateSession SessionID: %s, UserID: %d", sessionID, user.ID)
+	return sess, nil
 }
 
 // Delete session by id
 func (u *sessionUC) DeleteByID(ctx context.Context, sessionID string) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "session.sessionUC.DeleteByID")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "session.sessionUC.DeleteByID")
+	defer span.End()
 
-	return u.sessionRepo.DeleteByID(ctx, sessionID)
+	if err := u.sessionRepo.DeleteByID(ctx, sessionID); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Debugf("session.DeleteByID SessionID: %s", sessionID)
+	return nil
+}
+
+// Delete all sessions of a user, except keepSessionID when it's not empty
+func (u *sessionUC) DeleteByUserID(ctx context.Context, userID uint, keepSessionID string) error {
+	ctx, span := tracing.Start(ctx, "session.sessionUC.DeleteByUserID")
+	defer span.End()
+
+	return u.sessionRepo.DeleteByUserID(ctx, userID, keepSessionID)
 }
 
 // get session by id
 func (u *sessionUC) GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "session.sessionUC.GetSessionByID")
-	defer span.Finish()
+	ctx, span := tracing.Start(ctx, "session.sessionUC.GetSessionByID")
+	defer span.End()
 
 	return u.sessionRepo.GetSessionByID(ctx, sessionID)
 }
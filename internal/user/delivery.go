@@ -5,9 +5,23 @@ import "github.com/gin-gonic/gin"
 type Handlers interface {
 	UsersRegistration() gin.HandlerFunc
 	UsersLogin() gin.HandlerFunc
+	UsersLoginTwoFactor() gin.HandlerFunc
+	OAuthAuthorize() gin.HandlerFunc
+	OAuthCallback() gin.HandlerFunc
 	UserRetrieve() gin.HandlerFunc
 	UserUpdate() gin.HandlerFunc
+	ForgotPassword() gin.HandlerFunc
+	ResetPassword() gin.HandlerFunc
+	ChangePassword() gin.HandlerFunc
+	TwoFactorEnroll() gin.HandlerFunc
+	TwoFactorConfirm() gin.HandlerFunc
+	TwoFactorDisable() gin.HandlerFunc
+	TwoFactorRecoveryCodes() gin.HandlerFunc
+	AccessTokenCreate() gin.HandlerFunc
+	AccessTokenList() gin.HandlerFunc
+	AccessTokenRevoke() gin.HandlerFunc
 	ProfileRetrieve() gin.HandlerFunc
 	ProfileFollow() gin.HandlerFunc
 	ProfileUnfollow() gin.HandlerFunc
+	AccountUnlock() gin.HandlerFunc
 }
@@ -1,53 +1,64 @@
 package http
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gothinkster/golang-gin-realworld-example-app/config"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
-	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/httpErrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/locker"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
-	"github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type userHandlers struct {
 	cfg      *config.Config
 	userRepo user.Repository
+	userUC   user.UseCase
 	sessUC   session.UseCase
 	locker   locker.Locker
 }
 
-func NewUserHandlers(cfg *config.Config, userRepo user.Repository, sessUC session.UseCase, locker locker.Locker) user.Handlers {
-	return &userHandlers{cfg, userRepo, sessUC, locker}
+func NewUserHandlers(cfg *config.Config, userRepo user.Repository, userUC user.UseCase, sessUC session.UseCase, locker locker.Locker) user.Handlers {
+	return &userHandlers{cfg, userRepo, userUC, sessUC, locker}
 }
 
 func (h userHandlers) UsersRegistration() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "user.UsersRegistration")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		userModelValidator := NewUserModelValidator()
 		if err := userModelValidator.Bind(c); err != nil {
-			fmt.Print(err)
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewValidatorError(err))
+			c.Error(apperrors.Validation("user", err))
 			return
 		}
 		lockKey := fmt.Sprintf("user:email-%s", userModelValidator.userModel.Email)
-		lock := h.locker.ObtainLock(ctx, lockKey)
+		lock, err := h.locker.ObtainLock(ctx, lockKey)
+		if err != nil {
+			c.Error(locker.AppError(err))
+			return
+		}
 		defer lock.Release(ctx)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("lockToken", lock.Token()))
 
-		if err := h.userRepo.SaveOne(ctx, &userModelValidator.userModel); err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewError("database", err))
+		if err := h.userUC.Register(ctx, &userModelValidator.userModel); err != nil {
+			c.Error(err)
+			return
+		}
+		userSession, err := h.sessUC.CreateSession(ctx, &userModelValidator.userModel, h.cfg.Session.Expire)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		userSession, _ := h.sessUC.CreateSession(ctx, &userModelValidator.userModel, h.cfg.Session.Expire)
 		serializer := UserSerializer{ctx, userSession.Token, userModelValidator.userModel}
 
 		c.JSON(http.StatusCreated, gin.H{"user": serializer.Response()})
@@ -56,135 +67,419 @@ func (h userHandlers) UsersRegistration() gin.HandlerFunc {
 
 func (h userHandlers) UsersLogin() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "user.UsersLogin")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		loginValidator := NewLoginValidator()
 		if err := loginValidator.Bind(c); err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewValidatorError(err))
+			c.Error(apperrors.Validation("user", err))
 			return
 		}
-		userModel, err := h.userRepo.FindOneUser(ctx, &models.User{Email: loginValidator.userModel.Email})
+		userModel, err := h.userUC.Login(ctx, loginValidator.userModel.Email, loginValidator.User.Password, c.ClientIP())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		h.challengeOrCompleteLogin(ctx, c, userModel)
+	}
+}
 
+func (h userHandlers) OAuthAuthorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		authURL, err := h.userUC.OAuthAuthorize(ctx, c.Param("provider"))
 		if err != nil {
-			c.JSON(http.StatusForbidden, httpErrors.NewError("login", errors.New("Not Registered email or invalid password")))
+			c.Error(err)
 			return
 		}
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+func (h userHandlers) OAuthCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
 
-		if userModel.CheckPassword(loginValidator.User.Password) != nil {
-			c.JSON(http.StatusForbidden, httpErrors.NewError("login", errors.New("Not Registered email or invalid password")))
+		if providerErr := c.Query("error"); providerErr != "" {
+			c.Error(apperrors.Unauthorized("provider", errors.New(providerErr)))
 			return
 		}
-		userSession, _ := h.sessUC.CreateSession(ctx, &userModel, h.cfg.Session.Expire)
-		serializer := UserSerializer{ctx, userSession.Token, userModel}
-		c.JSON(http.StatusOK, gin.H{"user": serializer.Response()})
+		userModel, err := h.userUC.OAuthCallback(ctx, c.Param("provider"), c.Query("state"), c.Query("code"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		h.challengeOrCompleteLogin(ctx, c, userModel)
+	}
+}
+
+// The session is only created once the second factor is verified
+func (h userHandlers) challengeOrCompleteLogin(ctx context.Context, c *gin.Context, userModel *models.User) {
+	if userModel.TotpEnabled {
+		challenge, err := h.userUC.CreateLoginChallenge(ctx, userModel)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"twoFactor": gin.H{"challenge": challenge, "expiresIn": h.cfg.TwoFactor.ChallengeExpire}})
+		return
+	}
+	h.completeLogin(ctx, c, userModel)
+}
+
+func (h userHandlers) UsersLoginTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		twoFactorLoginValidator := NewTwoFactorLoginValidator()
+		if err := twoFactorLoginValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
+		userModel, err := h.userUC.VerifyLoginChallenge(ctx, twoFactorLoginValidator.User.Challenge, twoFactorLoginValidator.User.Code)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		h.completeLogin(ctx, c, userModel)
+	}
+}
+
+// Create the session of a logged in user and tell them about lockouts they haven't seen
+func (h userHandlers) completeLogin(ctx context.Context, c *gin.Context, userModel *models.User) {
+	userSession, err := h.sessUC.CreateSession(ctx, userModel, h.cfg.Session.Expire)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	serializer := UserSerializer{ctx, userSession.Token, *userModel}
+	response := gin.H{"user": serializer.Response()}
+
+	lockouts, err := h.userUC.PopLoginLockouts(ctx, userModel.ID)
+	if err == nil && len(lockouts) > 0 {
+		lockoutsSerializer := LoginLockoutsSerializer{ctx, lockouts}
+		response["lockouts"] = lockoutsSerializer.Response()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h userHandlers) TwoFactorEnroll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		userModel := c.MustGet("my_user_model").(models.User)
+		uri, err := h.userUC.EnrollTotp(ctx, &userModel)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"twoFactor": gin.H{"otpauthUri": uri}})
+	}
+}
+
+func (h userHandlers) TwoFactorConfirm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		totpCodeValidator := NewTotpCodeValidator()
+		if err := totpCodeValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
+		userModel := c.MustGet("my_user_model").(models.User)
+		recoveryCodes, err := h.userUC.ConfirmTotp(ctx, &userModel, totpCodeValidator.User.Code)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"twoFactor": gin.H{"enabled": true, "recoveryCodes": recoveryCodes}})
+	}
+}
+
+func (h userHandlers) TwoFactorDisable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		passwordValidator := NewPasswordConfirmValidator()
+		if err := passwordValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
+		userModel := c.MustGet("my_user_model").(models.User)
+		err := h.userUC.DisableTotp(ctx, &userModel, passwordValidator.User.Password)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"twoFactor": gin.H{"enabled": false}})
+	}
+}
+
+func (h userHandlers) TwoFactorRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		passwordValidator := NewPasswordConfirmValidator()
+		if err := passwordValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
+		userModel := c.MustGet("my_user_model").(models.User)
+		recoveryCodes, err := h.userUC.RegenerateRecoveryCodes(ctx, &userModel, passwordValidator.User.Password)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"twoFactor": gin.H{"enabled": true, "recoveryCodes": recoveryCodes}})
+	}
+}
+
+func (h userHandlers) AccountUnlock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		username := c.Param("username")
+		if err := h.userUC.UnlockAccount(ctx, username); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": "Unlock success"})
+	}
+}
+
+// Token of the session of the request, empty for personal access tokens which have none
+func (h userHandlers) sessionToken(ctx context.Context, c *gin.Context) string {
+	sessionID := c.GetString("my_session_id")
+	if sessionID == "" {
+		return ""
 	}
+	userSession, err := h.sessUC.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return ""
+	}
+	return userSession.Token
 }
 
 func (h userHandlers) UserRetrieve() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "user.UserRetrieve")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		userModel := c.MustGet("my_user_model").(models.User)
-		sessionID := c.MustGet("my_session_id").(string)
-		userSession, _ := h.sessUC.GetSessionByID(ctx, sessionID)
-		serializer := UserSerializer{ctx, userSession.Token, userModel}
+		serializer := UserSerializer{ctx, h.sessionToken(ctx, c), userModel}
 		c.JSON(http.StatusOK, gin.H{"user": serializer.Response()})
 	}
 }
 
 func (h userHandlers) UserUpdate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "user.UserUpdate")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		myUserModel := c.MustGet("my_user_model").(models.User)
 		userModelValidator := NewUserModelValidatorFillWith(myUserModel)
 		if err := userModelValidator.Bind(c); err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewValidatorError(err))
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+		// changes need the current password or a reset token and revoke the other sessions
+		if userModelValidator.PasswordSent() {
+			c.Error(user.ErrPasswordNotUpdatable)
+			return
+		}
+		// the email resets the password, a personal access token mustn't be able to take over the account
+		if c.GetString("my_session_id") == "" && userModelValidator.userModel.Email != myUserModel.Email {
+			c.Error(user.ErrEmailNeedsSession)
 			return
 		}
 
 		lockKey := fmt.Sprintf("user:email-%s", userModelValidator.userModel.Email)
-		lock := h.locker.ObtainLock(ctx, lockKey)
+		lock, err := h.locker.ObtainLock(ctx, lockKey)
+		if err != nil {
+			c.Error(locker.AppError(err))
+			return
+		}
 		defer lock.Release(ctx)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("lockToken", lock.Token()))
 
 		userModelValidator.userModel.ID = myUserModel.ID
-		if err := h.userRepo.Update(ctx, userModelValidator.userModel); err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewError("database", err))
+		if err := h.userRepo.Update(ctx, userModelValidator.userModel); postgres.IsUniqueViolation(err) {
+			c.Error(user.ErrUserTaken)
+			return
+		} else if err != nil {
+			c.Error(err)
 			return
 		}
+		serializer := UserSerializer{ctx, h.sessionToken(ctx, c), userModelValidator.userModel}
+
+		c.JSON(http.StatusOK, gin.H{"user": serializer.Response()})
+	}
+}
+
+func (h userHandlers) ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		forgotPasswordValidator := NewForgotPasswordValidator()
+		if err := forgotPasswordValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
+		if err := h.userUC.ForgotPassword(ctx, forgotPasswordValidator.User.Email); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": "Password reset email sent"})
+	}
+}
+
+func (h userHandlers) ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		resetPasswordValidator := NewResetPasswordValidator()
+		if err := resetPasswordValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
+		err := h.userUC.ResetPassword(ctx, resetPasswordValidator.User.Token, resetPasswordValidator.User.Password)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": "Password reset success"})
+	}
+}
+
+func (h userHandlers) ChangePassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		changePasswordValidator := NewChangePasswordValidator()
+		if err := changePasswordValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
 		userModel := c.MustGet("my_user_model").(models.User)
 		sessionID := c.MustGet("my_session_id").(string)
-		userSession, _ := h.sessUC.GetSessionByID(ctx, sessionID)
-		serializer := UserSerializer{ctx, userSession.Token, userModel}
+		err := h.userUC.ChangePassword(ctx, &userModel, sessionID, changePasswordValidator.User.CurrentPassword, changePasswordValidator.User.Password)
+		if err != nil {
+			c.Error(err)
+			return
+		}
 
+		serializer := UserSerializer{ctx, h.sessionToken(ctx, c), userModel}
 		c.JSON(http.StatusOK, gin.H{"user": serializer.Response()})
 	}
 }
 
-func (h userHandlers) ProfileRetrieve() gin.HandlerFunc {
+func (h userHandlers) AccessTokenCreate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "user.ProfileRetrieve")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
-		username := c.Param("username")
-		userModel, err := h.userRepo.FindOneUser(ctx, &models.User{Username: username})
+		accessTokenValidator := NewAccessTokenValidator()
+		if err := accessTokenValidator.Bind(c); err != nil {
+			c.Error(apperrors.Validation("user", err))
+			return
+		}
+
+		myUserModel := c.MustGet("my_user_model").(models.User)
+		token, tokenModel, err := h.userUC.CreateAccessToken(ctx, myUserModel.ID,
+			accessTokenValidator.tokenModel.Name,
+			accessTokenValidator.Token.Scopes,
+			accessTokenValidator.tokenModel.ExpiresAt,
+		)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("profile", errors.New("Invalid username")))
+			c.Error(err)
 			return
 		}
-		profileSerializer := ProfileSerializer{ctx, h.userRepo, userModel}
-		c.JSON(http.StatusOK, gin.H{"profile": profileSerializer.Response()})
+		serializer := AccessTokenSerializer{ctx, token, *tokenModel}
+		c.JSON(http.StatusCreated, gin.H{"token": serializer.Response()})
 	}
 }
-func (h userHandlers) ProfileFollow() gin.HandlerFunc {
+
+func (h userHandlers) AccessTokenList() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "user.ProfileFollow")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
-		username := c.Param("username")
-		userModel, err := h.userRepo.FindOneUser(ctx, &models.User{Username: username})
+		myUserModel := c.MustGet("my_user_model").(models.User)
+		tokens, err := h.userUC.GetAccessTokens(ctx, myUserModel.ID)
 		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("profile", errors.New("Invalid username")))
+			c.Error(err)
+			return
+		}
+		serializer := AccessTokensSerializer{ctx, tokens}
+		c.JSON(http.StatusOK, gin.H{"tokens": serializer.Response()})
+	}
+}
+
+func (h userHandlers) AccessTokenRevoke() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
+		id64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.Error(user.ErrAccessTokenMissing)
 			return
 		}
 		myUserModel := c.MustGet("my_user_model").(models.User)
-		err = h.userRepo.SetUserFollow(ctx, userModel.ID, myUserModel.ID)
+		err = h.userUC.RevokeAccessToken(ctx, myUserModel.ID, uint(id64))
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewError("database", err))
+			c.Error(err)
 			return
 		}
-		serializer := ProfileSerializer{ctx, h.userRepo, userModel}
-		c.JSON(http.StatusOK, gin.H{"profile": serializer.Response()})
+		c.JSON(http.StatusOK, gin.H{"token": "Revoke success"})
 	}
 }
-func (h userHandlers) ProfileUnfollow() gin.HandlerFunc {
+
+func (h userHandlers) ProfileRetrieve() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		span, ctx := opentracing.StartSpanFromContext(utils.GetRequestCtx(c), "user.ProfileUnfollow")
-		span.SetTag("requestId", utils.GetRequestID(c))
-		defer span.Finish()
+		ctx := utils.GetRequestCtx(c)
 
 		username := c.Param("username")
 		userModel, err := h.userRepo.FindOneUser(ctx, &models.User{Username: username})
-		if err != nil {
-			c.JSON(http.StatusNotFound, httpErrors.NewError("profile", errors.New("Invalid username")))
+		if postgres.IsRecordNotFoundError(err) {
+			c.Error(user.ErrProfileNotFound)
+			return
+		} else if err != nil {
+			c.Error(err)
 			return
 		}
+		profileSerializer := ProfileSerializer{ctx, h.userRepo, userModel}
+		c.JSON(http.StatusOK, gin.H{"profile": profileSerializer.Response()})
+	}
+}
+func (h userHandlers) ProfileFollow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
+
 		myUserModel := c.MustGet("my_user_model").(models.User)
+		userModel, err := h.userUC.Follow(ctx, c.Param("username"), myUserModel.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		serializer := ProfileSerializer{ctx, h.userRepo, *userModel}
+		c.JSON(http.StatusOK, gin.H{"profile": serializer.Response()})
+	}
+}
+func (h userHandlers) ProfileUnfollow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.GetRequestCtx(c)
 
-		err = h.userRepo.RemoveUserFollow(ctx, userModel.ID, myUserModel.ID)
+		myUserModel := c.MustGet("my_user_model").(models.User)
+		userModel, err := h.userUC.Unfollow(ctx, c.Param("username"), myUserModel.ID)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, httpErrors.NewError("database", err))
+			c.Error(err)
 			return
 		}
-		serializer := ProfileSerializer{ctx, h.userRepo, userModel}
+		serializer := ProfileSerializer{ctx, h.userRepo, *userModel}
 		c.JSON(http.StatusOK, gin.H{"profile": serializer.Response()})
 	}
 }
@@ -5,9 +5,15 @@ import (
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
 )
 
-func UsersRegister(router *gin.RouterGroup, h user.Handlers) {
-	router.POST("/", h.UsersRegistration())
+// idempotent makes registration safe to retry with an Idempotency-Key
+func UsersRegister(router *gin.RouterGroup, h user.Handlers, idempotent gin.HandlerFunc) {
+	router.POST("/", idempotent, h.UsersRegistration())
 	router.POST("/login", h.UsersLogin())
+	router.POST("/login/2fa", h.UsersLoginTwoFactor())
+	router.GET("/oauth/:provider", h.OAuthAuthorize())
+	router.GET("/oauth/:provider/callback", h.OAuthCallback())
+	router.POST("/password/forgot", h.ForgotPassword())
+	router.POST("/password/reset", h.ResetPassword())
 }
 
 func UserRegister(router *gin.RouterGroup, h user.Handlers) {
@@ -15,8 +21,24 @@ func UserRegister(router *gin.RouterGroup, h user.Handlers) {
 	router.PUT("/", h.UserUpdate())
 }
 
+// Credential management, personal access tokens can't reach these
+func UserSecurityRegister(router *gin.RouterGroup, h user.Handlers) {
+	router.PUT("/password", h.ChangePassword())
+	router.POST("/2fa/enroll", h.TwoFactorEnroll())
+	router.POST("/2fa/confirm", h.TwoFactorConfirm())
+	router.POST("/2fa/disable", h.TwoFactorDisable())
+	router.POST("/2fa/recovery-codes", h.TwoFactorRecoveryCodes())
+	router.GET("/tokens", h.AccessTokenList())
+	router.POST("/tokens", h.AccessTokenCreate())
+	router.DELETE("/tokens/:id", h.AccessTokenRevoke())
+}
+
 func ProfileRegister(router *gin.RouterGroup, h user.Handlers) {
 	router.GET("/:username", h.ProfileRetrieve())
 	router.POST("/:username/follow", h.ProfileFollow())
 	router.DELETE("/:username/follow", h.ProfileUnfollow())
 }
+
+func AdminUserRegister(router *gin.RouterGroup, h user.Handlers) {
+	router.DELETE("/:username/lockout", h.AccountUnlock())
+}
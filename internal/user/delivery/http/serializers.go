@@ -60,3 +60,75 @@ func (self *UserSerializer) Response() UserResponse {
 	}
 	return user
 }
+
+type LoginLockoutsSerializer struct {
+	c        context.Context
+	Lockouts []models.LoginLockout
+}
+
+type LoginLockoutResponse struct {
+	IP          string `json:"ip"`
+	LockedUntil string `json:"lockedUntil"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+func (self *LoginLockoutsSerializer) Response() []LoginLockoutResponse {
+	response := []LoginLockoutResponse{}
+	for _, lockout := range self.Lockouts {
+		response = append(response, LoginLockoutResponse{
+			IP:          lockout.IP,
+			LockedUntil: lockout.LockedUntil.UTC().Format("2006-01-02T15:04:05.999Z"),
+			CreatedAt:   lockout.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		})
+	}
+	return response
+}
+
+type AccessTokenSerializer struct {
+	c     context.Context
+	token string
+	models.PersonalAccessToken
+}
+
+type AccessTokensSerializer struct {
+	c      context.Context
+	Tokens []models.PersonalAccessToken
+}
+
+type AccessTokenResponse struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	Token      string   `json:"token,omitempty"`
+	ExpiresAt  *string  `json:"expiresAt"`
+	LastUsedAt *string  `json:"lastUsedAt"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+func (self *AccessTokenSerializer) Response() AccessTokenResponse {
+	response := AccessTokenResponse{
+		ID:        self.ID,
+		Name:      self.Name,
+		Scopes:    self.ScopeList(),
+		Token:     self.token,
+		CreatedAt: self.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+	}
+	if self.ExpiresAt != nil {
+		expiresAt := self.ExpiresAt.UTC().Format("2006-01-02T15:04:05.999Z")
+		response.ExpiresAt = &expiresAt
+	}
+	if self.LastUsedAt != nil {
+		lastUsedAt := self.LastUsedAt.UTC().Format("2006-01-02T15:04:05.999Z")
+		response.LastUsedAt = &lastUsedAt
+	}
+	return response
+}
+
+func (self *AccessTokensSerializer) Response() []AccessTokenResponse {
+	response := []AccessTokenResponse{}
+	for _, token := range self.Tokens {
+		serializer := AccessTokenSerializer{self.c, "", token}
+		response = append(response, serializer.Response())
+	}
+	return response
+}
@@ -1,6 +1,8 @@
 package http
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
@@ -42,6 +44,11 @@ func (self *UserModelValidator) Bind(c *gin.Context) error {
 	return nil
 }
 
+// Whether the request sent a password, updates filled with the user keep the placeholder
+func (self *UserModelValidator) PasswordSent() bool {
+	return self.User.Password != utils.NBRandomPassword
+}
+
 // You can put the default value of a Validator here
 func NewUserModelValidator() UserModelValidator {
 	userModelValidator := UserModelValidator{}
@@ -65,7 +72,7 @@ func NewUserModelValidatorFillWith(userModel models.User) UserModelValidator {
 type LoginValidator struct {
 	User struct {
 		Email    string `form:"email" json:"email" binding:"required,email"`
-		Password string `form:"password"json:"password" binding:"required,min=8,max=255"`
+		Password string `form:"password" json:"password" binding:"required,min=8,max=255"`
 	} `json:"user"`
 	userModel models.User `json:"-"`
 }
@@ -85,3 +92,117 @@ func NewLoginValidator() LoginValidator {
 	loginValidator := LoginValidator{}
 	return loginValidator
 }
+
+type ForgotPasswordValidator struct {
+	User struct {
+		Email string `form:"email" json:"email" binding:"required,email"`
+	} `json:"user"`
+}
+
+func (self *ForgotPasswordValidator) Bind(c *gin.Context) error {
+	return utils.ApplyGinValidator(c, self)
+}
+
+func NewForgotPasswordValidator() ForgotPasswordValidator {
+	return ForgotPasswordValidator{}
+}
+
+type ResetPasswordValidator struct {
+	User struct {
+		Token    string `form:"token" json:"token" binding:"required"`
+		Password string `form:"password" json:"password" binding:"required,min=8,max=255"`
+	} `json:"user"`
+}
+
+func (self *ResetPasswordValidator) Bind(c *gin.Context) error {
+	return utils.ApplyGinValidator(c, self)
+}
+
+func NewResetPasswordValidator() ResetPasswordValidator {
+	return ResetPasswordValidator{}
+}
+
+type ChangePasswordValidator struct {
+	User struct {
+		CurrentPassword string `form:"currentPassword" json:"currentPassword" binding:"required"`
+		Password        string `form:"password" json:"password" binding:"required,min=8,max=255"`
+	} `json:"user"`
+}
+
+func (self *ChangePasswordValidator) Bind(c *gin.Context) error {
+	return utils.ApplyGinValidator(c, self)
+}
+
+func NewChangePasswordValidator() ChangePasswordValidator {
+	return ChangePasswordValidator{}
+}
+
+type TwoFactorLoginValidator struct {
+	User struct {
+		Challenge string `form:"challenge" json:"challenge" binding:"required"`
+		Code      string `form:"code" json:"code" binding:"required,max=32"`
+	} `json:"user"`
+}
+
+func (self *TwoFactorLoginValidator) Bind(c *gin.Context) error {
+	return utils.ApplyGinValidator(c, self)
+}
+
+func NewTwoFactorLoginValidator() TwoFactorLoginValidator {
+	return TwoFactorLoginValidator{}
+}
+
+type TotpCodeValidator struct {
+	User struct {
+		Code string `form:"code" json:"code" binding:"required,len=6,numeric"`
+	} `json:"user"`
+}
+
+func (self *TotpCodeValidator) Bind(c *gin.Context) error {
+	return utils.ApplyGinValidator(c, self)
+}
+
+func NewTotpCodeValidator() TotpCodeValidator {
+	return TotpCodeValidator{}
+}
+
+type PasswordConfirmValidator struct {
+	User struct {
+		Password string `form:"password" json:"password" binding:"required"`
+	} `json:"user"`
+}
+
+func (self *PasswordConfirmValidator) Bind(c *gin.Context) error {
+	return utils.ApplyGinValidator(c, self)
+}
+
+func NewPasswordConfirmValidator() PasswordConfirmValidator {
+	return PasswordConfirmValidator{}
+}
+
+type AccessTokenValidator struct {
+	Token struct {
+		Name      string   `form:"name" json:"name" binding:"required,max=255"`
+		Scopes    []string `form:"scopes" json:"scopes" binding:"required,min=1,dive,oneof=articles:read articles:write profile:manage"`
+		ExpiresIn int      `form:"expiresIn" json:"expiresIn" binding:"omitempty,min=1,max=365"`
+	} `json:"token"`
+	tokenModel models.PersonalAccessToken `json:"-"`
+}
+
+func (self *AccessTokenValidator) Bind(c *gin.Context) error {
+	err := utils.ApplyGinValidator(c, self)
+	if err != nil {
+		return err
+	}
+	self.tokenModel.Name = self.Token.Name
+	// expiresIn is in days, tokens without it never expire
+	if self.Token.ExpiresIn > 0 {
+		expiresAt := time.Now().AddDate(0, 0, self.Token.ExpiresIn)
+		self.tokenModel.ExpiresAt = &expiresAt
+	}
+	return nil
+}
+
+func NewAccessTokenValidator() AccessTokenValidator {
+	return AccessTokenValidator{}
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"time"
 
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 )
@@ -11,7 +12,21 @@ type Repository interface {
 	SaveOne(c context.Context, data interface{}) error
 	Update(c context.Context, data models.User) error
 	IsUserFollowing(c context.Context, userId, followerId uint) bool
-	GetFollowingsByUser(ctx context.Context, userId uint) []models.User
+	GetFollowingsByUser(ctx context.Context, userId uint) ([]models.User, error)
 	SetUserFollow(c context.Context, userId, followerId uint) error
 	RemoveUserFollow(c context.Context, userId, followerId uint) error
+	CreateLoginLockout(c context.Context, lockout *models.LoginLockout) error
+	PopUnseenLoginLockouts(c context.Context, userId uint) ([]models.LoginLockout, error)
+	FindActiveLoginLockouts(c context.Context, userId uint) ([]models.LoginLockout, error)
+	UpdateTotp(c context.Context, userId uint, secret string, enabled bool) error
+	ReplaceRecoveryCodes(c context.Context, userId uint, codeHashes []string) error
+	UseRecoveryCode(c context.Context, userId uint, codeHash string) (bool, error)
+	CreateAccessToken(c context.Context, token *models.PersonalAccessToken) error
+	FindAccessTokens(c context.Context, userId uint) ([]models.PersonalAccessToken, error)
+	FindAccessTokenByHash(c context.Context, tokenHash string) (models.PersonalAccessToken, error)
+	DeleteAccessToken(c context.Context, userId, tokenId uint) (bool, error)
+	TouchAccessToken(c context.Context, tokenId uint, usedAt time.Time) error
+	FindIdentity(c context.Context, provider, subject string) (models.UserIdentity, error)
+	CreateIdentity(c context.Context, identity *models.UserIdentity) error
+	FindIdentitiesByUser(c context.Context, userId uint) ([]models.UserIdentity, error)
 }
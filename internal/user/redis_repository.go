@@ -0,0 +1,26 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+)
+
+// User redis repository
+type RedisRepository interface {
+	SetResetToken(ctx context.Context, tokenHash string, userID uint, expire int) error
+	PopResetToken(ctx context.Context, tokenHash string) (uint, error)
+	AddLoginFailure(ctx context.Context, key string, window int) (int, error)
+	CountLoginFailures(ctx context.Context, key string, window int) (int, error)
+	ResetLoginFailures(ctx context.Context, key string) error
+	SetLockout(ctx context.Context, key string, duration int) error
+	GetLockout(ctx context.Context, key string) (time.Duration, error)
+	SetLoginChallenge(ctx context.Context, tokenHash string, userID uint, expire int) error
+	GetLoginChallenge(ctx context.Context, tokenHash string) (uint, error)
+	IncrLoginChallengeAttempts(ctx context.Context, tokenHash string) (int, error)
+	DeleteLoginChallenge(ctx context.Context, tokenHash string) error
+	MarkTotpCodeUsed(ctx context.Context, userID uint, code string, expire int) (bool, error)
+	SetOAuthState(ctx context.Context, state string, data *models.OAuthState, expire int) error
+	PopOAuthState(ctx context.Context, state string) (*models.OAuthState, error)
+}
@@ -2,11 +2,12 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
 	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
 	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
-	"github.com/opentracing/opentracing-go"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
 )
 
 type userRepo struct {
@@ -19,58 +20,49 @@ func NewUserRepository(db *postgres.DB) user.Repository {
 }
 
 func (r *userRepo) FindOneUser(c context.Context, condition interface{}) (models.User, error) {
-	span, _ := opentracing.StartSpanFromContext(c, "user.userRepo.FindOneUser")
-	defer span.Finish()
+	c, span := tracing.Start(c, "user.userRepo.FindOneUser")
+	defer span.End()
 
 	var model models.User
-	err := r.db.Where(condition).First(&model).Error
+	err := postgres.WithContext(c, r.db).Where(condition).First(&model).Error
 	return model, err
 }
 
 func (r *userRepo) SaveOne(c context.Context, data interface{}) error {
-	span, _ := opentracing.StartSpanFromContext(c, "user.userRepo.SaveOne")
-	defer span.Finish()
+	c, span := tracing.Start(c, "user.userRepo.SaveOne")
+	defer span.End()
 
-	err := r.db.Save(data).Error
+	err := postgres.WithContext(c, r.db).Save(data).Error
 	return err
 }
 
 func (r *userRepo) Update(c context.Context, data models.User) error {
-	span, _ := opentracing.StartSpanFromContext(c, "user.userRepo.Update")
-	defer span.Finish()
+	c, span := tracing.Start(c, "user.userRepo.Update")
+	defer span.End()
 
-	err := r.db.Model(&models.User{ID: data.ID}).Update(data).Error
+	err := postgres.WithContext(c, r.db).Model(&models.User{ID: data.ID}).Update(data).Error
 	return err
 }
 
-func (r *userRepo) GetFollowingsByUser(c context.Context, userId uint) []models.User {
-	span, _ := opentracing.StartSpanFromContext(c, "user.userRepo.GetFollowingsByUser")
-	defer span.Finish()
+func (r *userRepo) GetFollowingsByUser(c context.Context, userId uint) ([]models.User, error) {
+	c, span := tracing.Start(c, "user.userRepo.GetFollowingsByUser")
+	defer span.End()
 
-	var tx *postgres.DB
-	tx = r.db.Begin()
-
-	var follows []models.Follow
 	var followings []models.User
-	tx.Where(models.Follow{
-		FollowedByID: userId,
-	}).Find(&follows)
-	for _, follow := range follows {
-		var userModel models.User
-		tx.Model(&follow).Related(&userModel, "Following")
-		followings = append(followings, userModel)
-	}
-	defer tx.Commit()
-
-	return followings
+	err := postgres.WithContext(c, r.db).
+		Joins("JOIN follow_models ON follow_models.following_id = user_models.id").
+		Where("follow_models.followed_by_id = ?", userId).
+		Order("follow_models.id").
+		Find(&followings).Error
+	return followings, err
 }
 
 func (r *userRepo) IsUserFollowing(c context.Context, userId, followerId uint) bool {
-	span, _ := opentracing.StartSpanFromContext(c, "user.userRepo.IsUserFollowing")
-	defer span.Finish()
+	c, span := tracing.Start(c, "user.userRepo.IsUserFollowing")
+	defer span.End()
 
 	var follow models.Follow
-	r.db.Where(models.Follow{
+	postgres.WithContext(c, r.db).Where(models.Follow{
 		FollowingID:  userId,
 		FollowedByID: followerId,
 	}).First(&follow)
@@ -78,24 +70,164 @@ func (r *userRepo) IsUserFollowing(c context.Context, userId, followerId uint) b
 }
 
 func (r *userRepo) SetUserFollow(c context.Context, userId, followerId uint) error {
-	span, _ := opentracing.StartSpanFromContext(c, "user.userRepo.SetUserFollow")
-	defer span.Finish()
-
-	var follow models.Follow
-	err := r.db.FirstOrCreate(&follow, &models.Follow{
-		FollowingID:  userId,
-		FollowedByID: followerId,
-	}).Error
+	c, span := tracing.Start(c, "user.userRepo.SetUserFollow")
+	defer span.End()
+
+	// FirstOrCreate races with a concurrent follow and would abort the transaction of the caller
+	err := postgres.WithContext(c, r.db).Exec(`
+		INSERT INTO follow_models (following_id, followed_by_id)
+		VALUES (?, ?)
+		ON CONFLICT DO NOTHING`, userId, followerId).Error
 	return err
 }
 
 func (r *userRepo) RemoveUserFollow(c context.Context, userId, followerId uint) error {
-	span, _ := opentracing.StartSpanFromContext(c, "user.userRepo.RemoveUserFollow")
-	defer span.Finish()
+	c, span := tracing.Start(c, "user.userRepo.RemoveUserFollow")
+	defer span.End()
 
-	err := r.db.Unscoped().Where(models.Follow{
+	err := postgres.WithContext(c, r.db).Unscoped().Where(models.Follow{
 		FollowingID:  userId,
 		FollowedByID: followerId,
 	}).Delete(models.Follow{}).Error
 	return err
 }
+
+func (r *userRepo) CreateLoginLockout(c context.Context, lockout *models.LoginLockout) error {
+	c, span := tracing.Start(c, "user.userRepo.CreateLoginLockout")
+	defer span.End()
+
+	err := postgres.WithContext(c, r.db).Create(lockout).Error
+	return err
+}
+
+func (r *userRepo) PopUnseenLoginLockouts(c context.Context, userId uint) ([]models.LoginLockout, error) {
+	c, span := tracing.Start(c, "user.userRepo.PopUnseenLoginLockouts")
+	defer span.End()
+
+	var lockouts []models.LoginLockout
+	err := postgres.WithContext(c, r.db).Where("user_id = ? AND seen = ?", userId, false).Order("created_at").Find(&lockouts).Error
+	if err != nil || len(lockouts) == 0 {
+		return lockouts, err
+	}
+	err = postgres.WithContext(c, r.db).Model(&models.LoginLockout{}).Where("user_id = ? AND seen = ?", userId, false).Update("seen", true).Error
+	return lockouts, err
+}
+
+// Lockouts of the user which haven't ended yet, seen or not
+func (r *userRepo) FindActiveLoginLockouts(c context.Context, userId uint) ([]models.LoginLockout, error) {
+	c, span := tracing.Start(c, "user.userRepo.FindActiveLoginLockouts")
+	defer span.End()
+
+	var lockouts []models.LoginLockout
+	err := postgres.WithContext(c, r.db).Where("user_id = ? AND locked_until > ?", userId, time.Now()).Order("created_at").Find(&lockouts).Error
+	return lockouts, err
+}
+
+func (r *userRepo) UpdateTotp(c context.Context, userId uint, secret string, enabled bool) error {
+	c, span := tracing.Start(c, "user.userRepo.UpdateTotp")
+	defer span.End()
+
+	// update with a map, so an empty secret and false are written too
+	err := postgres.WithContext(c, r.db).Model(&models.User{ID: userId}).Updates(map[string]interface{}{
+		"totp_secret":  secret,
+		"totp_enabled": enabled,
+	}).Error
+	return err
+}
+
+func (r *userRepo) ReplaceRecoveryCodes(c context.Context, userId uint, codeHashes []string) error {
+	ctx, span := tracing.Start(c, "user.userRepo.ReplaceRecoveryCodes")
+	defer span.End()
+
+	return postgres.RunInTransaction(ctx, r.db, func(ctx context.Context) error {
+		tx := postgres.WithContext(ctx, r.db)
+		if err := tx.Where("user_id = ?", userId).Delete(models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, codeHash := range codeHashes {
+			if err := tx.Create(&models.RecoveryCode{UserID: userId, CodeHash: codeHash}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *userRepo) UseRecoveryCode(c context.Context, userId uint, codeHash string) (bool, error) {
+	c, span := tracing.Start(c, "user.userRepo.UseRecoveryCode")
+	defer span.End()
+
+	now := time.Now()
+	result := postgres.WithContext(c, r.db).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).
+		Update("used_at", &now)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepo) CreateAccessToken(c context.Context, token *models.PersonalAccessToken) error {
+	c, span := tracing.Start(c, "user.userRepo.CreateAccessToken")
+	defer span.End()
+
+	err := postgres.WithContext(c, r.db).Create(token).Error
+	return err
+}
+
+func (r *userRepo) FindAccessTokens(c context.Context, userId uint) ([]models.PersonalAccessToken, error) {
+	c, span := tracing.Start(c, "user.userRepo.FindAccessTokens")
+	defer span.End()
+
+	var tokens []models.PersonalAccessToken
+	err := postgres.WithContext(c, r.db).Where("user_id = ?", userId).Order("created_at desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *userRepo) FindAccessTokenByHash(c context.Context, tokenHash string) (models.PersonalAccessToken, error) {
+	c, span := tracing.Start(c, "user.userRepo.FindAccessTokenByHash")
+	defer span.End()
+
+	var token models.PersonalAccessToken
+	err := postgres.WithContext(c, r.db).Where("token_hash = ?", tokenHash).First(&token).Error
+	return token, err
+}
+
+func (r *userRepo) DeleteAccessToken(c context.Context, userId, tokenId uint) (bool, error) {
+	c, span := tracing.Start(c, "user.userRepo.DeleteAccessToken")
+	defer span.End()
+
+	result := postgres.WithContext(c, r.db).Where("id = ? AND user_id = ?", tokenId, userId).Delete(models.PersonalAccessToken{})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepo) TouchAccessToken(c context.Context, tokenId uint, usedAt time.Time) error {
+	c, span := tracing.Start(c, "user.userRepo.TouchAccessToken")
+	defer span.End()
+
+	err := postgres.WithContext(c, r.db).Model(&models.PersonalAccessToken{ID: tokenId}).UpdateColumn("last_used_at", usedAt).Error
+	return err
+}
+
+func (r *userRepo) FindIdentity(c context.Context, provider, subject string) (models.UserIdentity, error) {
+	c, span := tracing.Start(c, "user.userRepo.FindIdentity")
+	defer span.End()
+
+	var identity models.UserIdentity
+	err := postgres.WithContext(c, r.db).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	return identity, err
+}
+
+func (r *userRepo) CreateIdentity(c context.Context, identity *models.UserIdentity) error {
+	c, span := tracing.Start(c, "user.userRepo.CreateIdentity")
+	defer span.End()
+
+	err := postgres.WithContext(c, r.db).Create(identity).Error
+	return err
+}
+
+func (r *userRepo) FindIdentitiesByUser(c context.Context, userId uint) ([]models.UserIdentity, error) {
+	c, span := tracing.Start(c, "user.userRepo.FindIdentitiesByUser")
+	defer span.End()
+
+	var identities []models.UserIdentity
+	err := postgres.WithContext(c, r.db).Where("user_id = ?", userId).Order("id").Find(&identities).Error
+	return identities, err
+}
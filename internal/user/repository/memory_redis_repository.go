@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	"github.com/pkg/errors"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// User redis repository of a single process, for running without redis.
+// Missing keys are redis.Nil like in the redis repository.
+type userMemoryRedisRepo struct {
+	mu       sync.Mutex
+	values   map[string]memoryValue
+	failures map[string][]time.Time
+}
+
+// zero expiresAt never expires
+type memoryValue struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// User memory redis repository constructor
+func NewUserMemoryRedisRepository() user.RedisRepository {
+	return &userMemoryRedisRepo{values: make(map[string]memoryValue), failures: make(map[string][]time.Time)}
+}
+
+func (r *userMemoryRedisRepo) set(key string, value interface{}, expire time.Duration) {
+	r.values[key] = memoryValue{value: value, expiresAt: time.Now().Add(expire)}
+}
+
+// ttl is zero for keys which never expire
+func (r *userMemoryRedisRepo) get(key string) (interface{}, time.Duration, bool) {
+	stored, ok := r.values[key]
+	if !ok {
+		return nil, 0, false
+	}
+	if stored.expiresAt.IsZero() {
+		return stored.value, 0, true
+	}
+	ttl := time.Until(stored.expiresAt)
+	if ttl <= 0 {
+		delete(r.values, key)
+		return nil, 0, false
+	}
+	return stored.value, ttl, true
+}
+
+func (r *userMemoryRedisRepo) SetResetToken(ctx context.Context, tokenHash string, userID uint, expire int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.set("reset:"+tokenHash, userID, time.Second*time.Duration(expire))
+	return nil
+}
+
+func (r *userMemoryRedisRepo) PopResetToken(ctx context.Context, tokenHash string) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, _, ok := r.get("reset:" + tokenHash)
+	if !ok {
+		return 0, errors.Wrap(goredis.Nil, "userMemoryRedisRepo.PopResetToken")
+	}
+	delete(r.values, "reset:"+tokenHash)
+	return value.(uint), nil
+}
+
+// Failures older than the window are dropped
+func (r *userMemoryRedisRepo) recentFailures(key string, window int) []time.Time {
+	since := time.Now().Add(-time.Duration(window) * time.Second)
+	recent := r.failures[key][:0]
+	for _, failedAt := range r.failures[key] {
+		if failedAt.After(since) {
+			recent = append(recent, failedAt)
+		}
+	}
+	r.failures[key] = recent
+	return recent
+}
+
+func (r *userMemoryRedisRepo) AddLoginFailure(ctx context.Context, key string, window int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures[key] = append(r.recentFailures(key, window), time.Now())
+	return len(r.failures[key]), nil
+}
+
+func (r *userMemoryRedisRepo) CountLoginFailures(ctx context.Context, key string, window int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.recentFailures(key, window)), nil
+}
+
+func (r *userMemoryRedisRepo) ResetLoginFailures(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.failures, key)
+	delete(r.values, "lockout:"+key)
+	return nil
+}
+
+func (r *userMemoryRedisRepo) SetLockout(ctx context.Context, key string, duration int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.set("lockout:"+key, true, time.Duration(duration)*time.Second)
+	return nil
+}
+
+func (r *userMemoryRedisRepo) GetLockout(ctx context.Context, key string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ttl, _ := r.get("lockout:" + key)
+	return ttl, nil
+}
+
+func (r *userMemoryRedisRepo) SetLoginChallenge(ctx context.Context, tokenHash string, userID uint, expire int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.set("challenge:"+tokenHash, userID, time.Second*time.Duration(expire))
+	return nil
+}
+
+func (r *userMemoryRedisRepo) GetLoginChallenge(ctx context.Context, tokenHash string) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, _, ok := r.get("challenge:" + tokenHash)
+	if !ok {
+		return 0, errors.Wrap(goredis.Nil, "userMemoryRedisRepo.GetLoginChallenge")
+	}
+	return value.(uint), nil
+}
+
+// The counter lives as long as the challenge
+func (r *userMemoryRedisRepo) IncrLoginChallengeAttempts(ctx context.Context, tokenHash string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attemptsKey := "challenge:" + tokenHash + ":attempts"
+	stored := memoryValue{value: 1}
+	if value, _, ok := r.get(attemptsKey); ok {
+		stored = r.values[attemptsKey]
+		stored.value = value.(int) + 1
+	}
+	if _, ttl, ok := r.get("challenge:" + tokenHash); ok && ttl > 0 {
+		stored.expiresAt = time.Now().Add(ttl)
+	}
+	r.values[attemptsKey] = stored
+	return stored.value.(int), nil
+}
+
+func (r *userMemoryRedisRepo) DeleteLoginChallenge(ctx context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.values, "challenge:"+tokenHash)
+	delete(r.values, "challenge:"+tokenHash+":attempts")
+	return nil
+}
+
+func (r *userMemoryRedisRepo) MarkTotpCodeUsed(ctx context.Context, userID uint, code string, expire int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := fmt.Sprintf("used:%d:%s", userID, code)
+	if _, _, ok := r.get(key); ok {
+		return false, nil
+	}
+	r.set(key, true, time.Second*time.Duration(expire))
+	return true, nil
+}
+
+func (r *userMemoryRedisRepo) SetOAuthState(ctx context.Context, state string, data *models.OAuthState, expire int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.set("state:"+state, *data, time.Second*time.Duration(expire))
+	return nil
+}
+
+func (r *userMemoryRedisRepo) PopOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, _, ok := r.get("state:" + state)
+	if !ok {
+		return nil, errors.Wrap(goredis.Nil, "userMemoryRedisRepo.PopOAuthState")
+	}
+	delete(r.values, "state:"+state)
+	data := value.(models.OAuthState)
+	return &data, nil
+}
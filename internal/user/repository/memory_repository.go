@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// User repository of a single process, for running without postgres. It keeps
+// the semantics of the gorm repository: missing records are gorm.ErrRecordNotFound
+// and unique indexes fail with the unique violation of postgres.
+type userMemoryRepo struct {
+	mu            sync.RWMutex
+	lastID        uint
+	users         map[uint]models.User
+	follows       []models.Follow
+	lockouts      []models.LoginLockout
+	recoveryCodes []models.RecoveryCode
+	accessTokens  []models.PersonalAccessToken
+	identities    []models.UserIdentity
+}
+
+func NewUserMemoryRepository() user.Repository {
+	return &userMemoryRepo{users: make(map[uint]models.User)}
+}
+
+// ids are shared by all tables, which is fine as long as they are unique per table
+func (r *userMemoryRepo) nextID() uint {
+	r.lastID++
+	return r.lastID
+}
+
+func uniqueViolation(constraint string) error {
+	return &pq.Error{Code: "23505", Message: fmt.Sprintf("duplicate key value violates unique constraint %q", constraint), Constraint: constraint}
+}
+
+// Non zero fields of condition have to match, like gorm's struct conditions
+func userCondition(condition interface{}) (models.User, error) {
+	switch c := condition.(type) {
+	case *models.User:
+		return *c, nil
+	case models.User:
+		return c, nil
+	}
+	return models.User{}, fmt.Errorf("userMemoryRepo: unsupported condition %T", condition)
+}
+
+func matchUser(u, condition models.User) bool {
+	return (condition.ID == 0 || u.ID == condition.ID) &&
+		(condition.Username == "" || u.Username == condition.Username) &&
+		(condition.Email == "" || u.Email == condition.Email) &&
+		(condition.Role == "" || u.Role == condition.Role)
+}
+
+func (r *userMemoryRepo) sortedUserIDs() []uint {
+	ids := make([]uint, 0, len(r.users))
+	for id := range r.users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (r *userMemoryRepo) FindOneUser(c context.Context, condition interface{}) (models.User, error) {
+	cond, err := userCondition(condition)
+	if err != nil {
+		return models.User{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, id := range r.sortedUserIDs() {
+		if matchUser(r.users[id], cond) {
+			return r.users[id], nil
+		}
+	}
+	return models.User{}, gorm.ErrRecordNotFound
+}
+
+func (r *userMemoryRepo) emailTaken(email string, exceptID uint) bool {
+	for id, u := range r.users {
+		if id != exceptID && u.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *userMemoryRepo) SaveOne(c context.Context, data interface{}) error {
+	userModel, ok := data.(*models.User)
+	if !ok {
+		return fmt.Errorf("userMemoryRepo: can't save %T", data)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.emailTaken(userModel.Email, userModel.ID) {
+		return uniqueViolation("uix_user_models_email")
+	}
+	now := time.Now()
+	if userModel.ID == 0 {
+		userModel.ID = r.nextID()
+		userModel.CreatedAt = now
+	}
+	if userModel.Role == "" {
+		userModel.Role = models.RoleUser
+	}
+	userModel.UpdatedAt = now
+	r.users[userModel.ID] = *userModel
+	return nil
+}
+
+// Only the non zero fields of data are written
+func (r *userMemoryRepo) Update(c context.Context, data models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userModel, ok := r.users[data.ID]
+	if !ok {
+		return nil
+	}
+	if data.Email != "" {
+		if r.emailTaken(data.Email, data.ID) {
+			return uniqueViolation("uix_user_models_email")
+		}
+		userModel.Email = data.Email
+	}
+	if data.Username != "" {
+		userModel.Username = data.Username
+	}
+	if data.Bio != "" {
+		userModel.Bio = data.Bio
+	}
+	if data.Image != nil {
+		userModel.Image = data.Image
+	}
+	if data.PasswordHash != "" {
+		userModel.PasswordHash = data.PasswordHash
+	}
+	if data.Role != "" {
+		userModel.Role = data.Role
+	}
+	if data.TotpSecret != "" {
+		userModel.TotpSecret = data.TotpSecret
+	}
+	if data.TotpEnabled {
+		userModel.TotpEnabled = true
+	}
+	userModel.UpdatedAt = time.Now()
+	r.users[data.ID] = userModel
+	return nil
+}
+
+func (r *userMemoryRepo) IsUserFollowing(c context.Context, userId, followerId uint) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, follow := range r.follows {
+		if follow.FollowingID == userId && follow.FollowedByID == followerId {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *userMemoryRepo) GetFollowingsByUser(c context.Context, userId uint) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var followings []models.User
+	for _, follow := range r.follows {
+		if userModel, ok := r.users[follow.FollowingID]; ok && follow.FollowedByID == userId {
+			followings = append(followings, userModel)
+		}
+	}
+	return followings, nil
+}
+
+func (r *userMemoryRepo) SetUserFollow(c context.Context, userId, followerId uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, follow := range r.follows {
+		if follow.FollowingID == userId && follow.FollowedByID == followerId {
+			return nil
+		}
+	}
+	r.follows = append(r.follows, models.Follow{ID: r.nextID(), FollowingID: userId, FollowedByID: followerId})
+	return nil
+}
+
+func (r *userMemoryRepo) RemoveUserFollow(c context.Context, userId, followerId uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	follows := r.follows[:0]
+	for _, follow := range r.follows {
+		if follow.FollowingID != userId || follow.FollowedByID != followerId {
+			follows = append(follows, follow)
+		}
+	}
+	r.follows = follows
+	return nil
+}
+
+func (r *userMemoryRepo) CreateLoginLockout(c context.Context, lockout *models.LoginLockout) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockout.ID = r.nextID()
+	lockout.CreatedAt = time.Now()
+	r.lockouts = append(r.lockouts, *lockout)
+	return nil
+}
+
+func (r *userMemoryRepo) PopUnseenLoginLockouts(c context.Context, userId uint) ([]models.LoginLockout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lockouts []models.LoginLockout
+	for i := range r.lockouts {
+		if r.lockouts[i].UserID == userId && !r.lockouts[i].Seen {
+			lockouts = append(lockouts, r.lockouts[i])
+			r.lockouts[i].Seen = true
+		}
+	}
+	return lockouts, nil
+}
+
+// Lockouts of the user which haven't ended yet, seen or not
+func (r *userMemoryRepo) FindActiveLoginLockouts(c context.Context, userId uint) ([]models.LoginLockout, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var lockouts []models.LoginLockout
+	now := time.Now()
+	for _, lockout := range r.lockouts {
+		if lockout.UserID == userId && lockout.LockedUntil.After(now) {
+			lockouts = append(lockouts, lockout)
+		}
+	}
+	return lockouts, nil
+}
+
+func (r *userMemoryRepo) UpdateTotp(c context.Context, userId uint, secret string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if userModel, ok := r.users[userId]; ok {
+		userModel.TotpSecret = secret
+		userModel.TotpEnabled = enabled
+		r.users[userId] = userModel
+	}
+	return nil
+}
+
+func (r *userMemoryRepo) ReplaceRecoveryCodes(c context.Context, userId uint, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := r.recoveryCodes[:0]
+	for _, code := range r.recoveryCodes {
+		if code.UserID != userId {
+			codes = append(codes, code)
+		}
+	}
+	for _, codeHash := range codeHashes {
+		codes = append(codes, models.RecoveryCode{ID: r.nextID(), UserID: userId, CodeHash: codeHash, CreatedAt: time.Now()})
+	}
+	r.recoveryCodes = codes
+	return nil
+}
+
+func (r *userMemoryRepo) UseRecoveryCode(c context.Context, userId uint, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, code := range r.recoveryCodes {
+		if code.UserID == userId && code.CodeHash == codeHash && code.UsedAt == nil {
+			now := time.Now()
+			r.recoveryCodes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *userMemoryRepo) CreateAccessToken(c context.Context, token *models.PersonalAccessToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.accessTokens {
+		if existing.TokenHash == token.TokenHash {
+			return uniqueViolation("uix_personal_access_token_models_token_hash")
+		}
+	}
+	token.ID = r.nextID()
+	token.CreatedAt = time.Now()
+	r.accessTokens = append(r.accessTokens, *token)
+	return nil
+}
+
+func (r *userMemoryRepo) FindAccessTokens(c context.Context, userId uint) ([]models.PersonalAccessToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tokens []models.PersonalAccessToken
+	// newest first
+	for i := len(r.accessTokens) - 1; i >= 0; i-- {
+		if r.accessTokens[i].UserID == userId {
+			tokens = append(tokens, r.accessTokens[i])
+		}
+	}
+	return tokens, nil
+}
+
+func (r *userMemoryRepo) FindAccessTokenByHash(c context.Context, tokenHash string) (models.PersonalAccessToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, token := range r.accessTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return models.PersonalAccessToken{}, gorm.ErrRecordNotFound
+}
+
+func (r *userMemoryRepo) DeleteAccessToken(c context.Context, userId, tokenId uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, token := range r.accessTokens {
+		if token.ID == tokenId && token.UserID == userId {
+			r.accessTokens = append(r.accessTokens[:i], r.accessTokens[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *userMemoryRepo) TouchAccessToken(c context.Context, tokenId uint, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, token := range r.accessTokens {
+		if token.ID == tokenId {
+			r.accessTokens[i].LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func (r *userMemoryRepo) FindIdentity(c context.Context, provider, subject string) (models.UserIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return models.UserIdentity{}, gorm.ErrRecordNotFound
+}
+
+func (r *userMemoryRepo) CreateIdentity(c context.Context, identity *models.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return uniqueViolation("idx_provider_subject")
+		}
+	}
+	identity.ID = r.nextID()
+	identity.CreatedAt = time.Now()
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *userMemoryRepo) FindIdentitiesByUser(c context.Context, userId uint) ([]models.UserIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var identities []models.UserIdentity
+	for _, identity := range r.identities {
+		if identity.UserID == userId {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
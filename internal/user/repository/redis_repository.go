@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/redis"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+	"github.com/pkg/errors"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// User redis repository
+type userRedisRepo struct {
+	redisClient *redis.Client
+	appName     string
+	resetPrefix string
+	loginPrefix string
+	tfaPrefix   string
+	oauthPrefix string
+}
+
+// User redis repository constructor
+func NewUserRedisRepository(cfg *config.Config, redisClient *redis.Client) user.RedisRepository {
+	return &userRedisRepo{redisClient: redisClient, appName: cfg.Server.AppName, resetPrefix: cfg.PasswordReset.Prefix, loginPrefix: cfg.LoginThrottle.Prefix, tfaPrefix: cfg.TwoFactor.Prefix, oauthPrefix: cfg.OAuth.Prefix}
+}
+
+// Store a hashed password reset token which expires after expire seconds
+func (r *userRedisRepo) SetResetToken(ctx context.Context, tokenHash string, userID uint, expire int) error {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.SetResetToken")
+	defer span.End()
+
+	if err := r.redisClient.Set(ctx, r.buildResetKey(tokenHash), userID, time.Second*time.Duration(expire)).Err(); err != nil {
+		return errors.Wrap(err, "userRedisRepo.SetResetToken.redisClient.Set")
+	}
+	return nil
+}
+
+// Get and delete a password reset token, so it can be used only once
+func (r *userRedisRepo) PopResetToken(ctx context.Context, tokenHash string) (uint, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.PopResetToken")
+	defer span.End()
+
+	value, err := r.redisClient.GetDel(ctx, r.buildResetKey(tokenHash)).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.PopResetToken.redisClient.GetDel")
+	}
+	userID, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.PopResetToken.strconv.ParseUint")
+	}
+	return uint(userID), nil
+}
+
+// Record a failed login in a sliding window and return the number of failures inside the window
+func (r *userRedisRepo) AddLoginFailure(ctx context.Context, key string, window int) (int, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.AddLoginFailure")
+	defer span.End()
+
+	now := time.Now()
+	failuresKey := r.buildFailuresKey(key)
+	pipe := r.redisClient.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, failuresKey, "-inf", strconv.FormatInt(now.Add(-time.Duration(window)*time.Second).UnixNano(), 10))
+	pipe.ZAdd(ctx, failuresKey, goredis.Z{Score: float64(now.UnixNano()), Member: now.UnixNano()})
+	count := pipe.ZCard(ctx, failuresKey)
+	pipe.Expire(ctx, failuresKey, time.Duration(window)*time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.AddLoginFailure.redisClient.ZAdd")
+	}
+	return int(count.Val()), nil
+}
+
+// Count the failed logins inside the sliding window
+func (r *userRedisRepo) CountLoginFailures(ctx context.Context, key string, window int) (int, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.CountLoginFailures")
+	defer span.End()
+
+	min := strconv.FormatInt(time.Now().Add(-time.Duration(window)*time.Second).UnixNano(), 10)
+	count, err := r.redisClient.ZCount(ctx, r.buildFailuresKey(key), min, "+inf").Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.CountLoginFailures.redisClient.ZCount")
+	}
+	return int(count), nil
+}
+
+// Clear the failed logins and any lockout of the key
+func (r *userRedisRepo) ResetLoginFailures(ctx context.Context, key string) error {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.ResetLoginFailures")
+	defer span.End()
+
+	if err := r.redisClient.Del(ctx, r.buildFailuresKey(key), r.buildLockoutKey(key)).Err(); err != nil {
+		return errors.Wrap(err, "userRedisRepo.ResetLoginFailures.redisClient.Del")
+	}
+	return nil
+}
+
+// Lock the key out of logins for duration seconds
+func (r *userRedisRepo) SetLockout(ctx context.Context, key string, duration int) error {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.SetLockout")
+	defer span.End()
+
+	if err := r.redisClient.Set(ctx, r.buildLockoutKey(key), 1, time.Duration(duration)*time.Second).Err(); err != nil {
+		return errors.Wrap(err, "userRedisRepo.SetLockout.redisClient.Set")
+	}
+	return nil
+}
+
+// Get the remaining lockout of the key, zero when it isn't locked
+func (r *userRedisRepo) GetLockout(ctx context.Context, key string) (time.Duration, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.GetLockout")
+	defer span.End()
+
+	ttl, err := r.redisClient.PTTL(ctx, r.buildLockoutKey(key)).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.GetLockout.redisClient.PTTL")
+	}
+	// negative ttl means the key doesn't exist or never expires
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Store a hashed two factor login challenge which expires after expire seconds
+func (r *userRedisRepo) SetLoginChallenge(ctx context.Context, tokenHash string, userID uint, expire int) error {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.SetLoginChallenge")
+	defer span.End()
+
+	if err := r.redisClient.Set(ctx, r.buildChallengeKey(tokenHash), userID, time.Second*time.Duration(expire)).Err(); err != nil {
+		return errors.Wrap(err, "userRedisRepo.SetLoginChallenge.redisClient.Set")
+	}
+	return nil
+}
+
+// Get the user of a two factor login challenge
+func (r *userRedisRepo) GetLoginChallenge(ctx context.Context, tokenHash string) (uint, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.GetLoginChallenge")
+	defer span.End()
+
+	userID, err := r.redisClient.Get(ctx, r.buildChallengeKey(tokenHash)).Uint64()
+	if err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.GetLoginChallenge.redisClient.Get")
+	}
+	return uint(userID), nil
+}
+
+// Count a failed code for a two factor login challenge, the counter lives as long as the challenge
+func (r *userRedisRepo) IncrLoginChallengeAttempts(ctx context.Context, tokenHash string) (int, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.IncrLoginChallengeAttempts")
+	defer span.End()
+
+	challengeKey := r.buildChallengeKey(tokenHash)
+	attemptsKey := challengeKey + ":attempts"
+	ttl, err := r.redisClient.PTTL(ctx, challengeKey).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.IncrLoginChallengeAttempts.redisClient.PTTL")
+	}
+
+	pipe := r.redisClient.TxPipeline()
+	attempts := pipe.Incr(ctx, attemptsKey)
+	if ttl > 0 {
+		pipe.PExpire(ctx, attemptsKey, ttl)
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return 0, errors.Wrap(err, "userRedisRepo.IncrLoginChallengeAttempts.redisClient.Incr")
+	}
+	return int(attempts.Val()), nil
+}
+
+// Delete a two factor login challenge and its attempts
+func (r *userRedisRepo) DeleteLoginChallenge(ctx context.Context, tokenHash string) error {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.DeleteLoginChallenge")
+	defer span.End()
+
+	challengeKey := r.buildChallengeKey(tokenHash)
+	if err := r.redisClient.Del(ctx, challengeKey, challengeKey+":attempts").Err(); err != nil {
+		return errors.Wrap(err, "userRedisRepo.DeleteLoginChallenge.redisClient.Del")
+	}
+	return nil
+}
+
+// Remember a totp code for expire seconds, returns false if it was already used so codes can't be replayed
+func (r *userRedisRepo) MarkTotpCodeUsed(ctx context.Context, userID uint, code string, expire int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.MarkTotpCodeUsed")
+	defer span.End()
+
+	key := fmt.Sprintf("%s:%s:used:%d:%s", r.appName, r.tfaPrefix, userID, code)
+	ok, err := r.redisClient.SetNX(ctx, key, 1, time.Second*time.Duration(expire)).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "userRedisRepo.MarkTotpCodeUsed.redisClient.SetNX")
+	}
+	return ok, nil
+}
+
+// Store a pending oauth authorization request which expires after expire seconds
+func (r *userRedisRepo) SetOAuthState(ctx context.Context, state string, data *models.OAuthState, expire int) error {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.SetOAuthState")
+	defer span.End()
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return errors.WithMessage(err, "userRedisRepo.SetOAuthState.json.Marshal")
+	}
+	if err = r.redisClient.Set(ctx, r.buildOAuthStateKey(state), dataBytes, time.Second*time.Duration(expire)).Err(); err != nil {
+		return errors.Wrap(err, "userRedisRepo.SetOAuthState.redisClient.Set")
+	}
+	return nil
+}
+
+// Get and delete a pending oauth authorization request, so a state can be used only once
+func (r *userRedisRepo) PopOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	ctx, span := tracing.Start(ctx, "user.userRedisRepo.PopOAuthState")
+	defer span.End()
+
+	dataBytes, err := r.redisClient.GetDel(ctx, r.buildOAuthStateKey(state)).Bytes()
+	if err != nil {
+		return nil, errors.Wrap(err, "userRedisRepo.PopOAuthState.redisClient.GetDel")
+	}
+	data := &models.OAuthState{}
+	if err = json.Unmarshal(dataBytes, data); err != nil {
+		return nil, errors.Wrap(err, "userRedisRepo.PopOAuthState.json.Unmarshal")
+	}
+	return data, nil
+}
+
+func (r *userRedisRepo) buildOAuthStateKey(state string) string {
+	return fmt.Sprintf("%s:%s:state:%s", r.appName, r.oauthPrefix, utils.HashToken(state))
+}
+
+func (r *userRedisRepo) buildChallengeKey(tokenHash string) string {
+	return fmt.Sprintf("%s:%s:challenge:%s", r.appName, r.tfaPrefix, tokenHash)
+}
+
+func (r *userRedisRepo) buildFailuresKey(key string) string {
+	return fmt.Sprintf("%s:%s:failures:%s", r.appName, r.loginPrefix, key)
+}
+
+func (r *userRedisRepo) buildLockoutKey(key string) string {
+	return fmt.Sprintf("%s:%s:lockout:%s", r.appName, r.loginPrefix, key)
+}
+
+func (r *userRedisRepo) buildResetKey(tokenHash string) string {
+	return fmt.Sprintf("%s:%s:%s", r.appName, r.resetPrefix, tokenHash)
+}
@@ -0,0 +1,74 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+)
+
+var (
+	ErrInvalidResetToken    = apperrors.Validation("token", errors.New("Invalid or expired token"))
+	ErrInvalidPassword      = apperrors.Forbidden("password", errors.New("Invalid password"))
+	ErrInvalidCredentials   = apperrors.Forbidden("login", errors.New("Not Registered email or invalid password"))
+	ErrTooManyLoginAttempts = errors.New("Too many failed login attempts, try again later")
+	ErrInvalidChallenge     = apperrors.Unauthorized("challenge", errors.New("Invalid or expired challenge"))
+	ErrInvalidTotpCode      = apperrors.Forbidden("code", errors.New("Invalid code"))
+	ErrTotpEnabled          = apperrors.Conflict("twoFactor", errors.New("Two factor auth is already enabled"))
+	ErrTotpNotEnabled       = apperrors.Conflict("twoFactor", errors.New("Two factor auth is not enabled"))
+	ErrTotpNotEnrolled      = apperrors.Validation("code", errors.New("Two factor auth enrollment not started"))
+	ErrInvalidAccessToken   = apperrors.Unauthorized("token", errors.New("Invalid or expired access token"))
+	ErrAccessTokenMissing   = apperrors.NotFound("token", errors.New("Access token not found"))
+	ErrInvalidOAuthState    = apperrors.Unauthorized("state", errors.New("Invalid or expired state"))
+	ErrUnverifiedEmail      = apperrors.Forbidden("email", errors.New("Email is not verified by the provider"))
+	ErrUnknownProvider      = apperrors.NotFound("provider", errors.New("Unknown provider"))
+	ErrProfileNotFound      = apperrors.NotFound("profile", errors.New("Invalid username"))
+	ErrUserTaken            = apperrors.Conflict("user", errors.New("Email or username is already taken"))
+	ErrInvalidRole          = apperrors.Validation("role", errors.New("Unknown role"))
+	ErrPasswordNotUpdatable = apperrors.Validation("password", errors.New("Change the password with PUT /api/user/password"))
+	ErrEmailNeedsSession    = apperrors.Forbidden("email", errors.New("Changing the email requires a session"))
+)
+
+// A user's account data, for data exports
+type Export struct {
+	User         models.User
+	Identities   []models.UserIdentity
+	AccessTokens []models.PersonalAccessToken
+	Followings   []models.User
+}
+
+// Returned while logins are locked out after too many failed attempts
+func NewLockedError(retryAfter time.Duration) error {
+	return apperrors.TooManyRequests("login", ErrTooManyLoginAttempts, retryAfter)
+}
+
+type UseCase interface {
+	Register(ctx context.Context, user *models.User) error
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	SetRole(ctx context.Context, username, role string) (*models.User, error)
+	SetPassword(ctx context.Context, username, password string) error
+	RevokeSessions(ctx context.Context, username string) error
+	Export(ctx context.Context, username string) (*Export, error)
+	Follow(ctx context.Context, username string, followerID uint) (*models.User, error)
+	Unfollow(ctx context.Context, username string, followerID uint) (*models.User, error)
+	Login(ctx context.Context, email, password, clientIP string) (*models.User, error)
+	PopLoginLockouts(ctx context.Context, userID uint) ([]models.LoginLockout, error)
+	UnlockAccount(ctx context.Context, username string) error
+	CreateLoginChallenge(ctx context.Context, user *models.User) (string, error)
+	VerifyLoginChallenge(ctx context.Context, challenge, code string) (*models.User, error)
+	EnrollTotp(ctx context.Context, user *models.User) (string, error)
+	ConfirmTotp(ctx context.Context, user *models.User, code string) ([]string, error)
+	DisableTotp(ctx context.Context, user *models.User, password string) error
+	RegenerateRecoveryCodes(ctx context.Context, user *models.User, password string) ([]string, error)
+	CreateAccessToken(ctx context.Context, userID uint, name string, scopes []string, expiresAt *time.Time) (string, *models.PersonalAccessToken, error)
+	GetAccessTokens(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID, tokenID uint) error
+	AuthenticateAccessToken(ctx context.Context, token string) (*models.PersonalAccessToken, error)
+	OAuthAuthorize(ctx context.Context, provider string) (string, error)
+	OAuthCallback(ctx context.Context, provider, state, code string) (*models.User, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	ChangePassword(ctx context.Context, user *models.User, sessionID, currentPassword, password string) error
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	registrationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "users",
+			Name:      "registrations_total",
+			Help:      "How many users signed up, partitioned by method.",
+		},
+		[]string{"method"},
+	)
+	loginsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "users",
+			Name:      "logins_total",
+			Help:      "How many logins were attempted, partitioned by method and result.",
+		},
+		[]string{"method", "result"},
+	)
+	followsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "users",
+			Name:      "follows_total",
+			Help:      "How many profiles were followed and unfollowed, partitioned by action.",
+		},
+		[]string{"action"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(registrationsTotal, loginsTotal, followsTotal)
+}
+
+// Result label of a login attempt, lockouts and errors of redis are failures too
+func loginResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,802 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/models"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/session"
+	"github.com/gothinkster/golang-gin-realworld-example-app/internal/user"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/db/postgres"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/mailer"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/oauth"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	resetTokenSize     = 32
+	challengeTokenSize = 32
+	recoveryCodeSize   = 5
+	accessTokenSize    = 32
+	oauthSecretSize    = 32
+	oauthUsernameTries = 5
+	// last use of access tokens is written at most once per interval
+	accessTokenTouchInterval = time.Minute
+	maxDelayShift            = 16
+	// a totp code is valid for the current period and one on each side
+	totpCodeExpire = 90
+)
+
+// User UseCase
+type userUC struct {
+	cfg           *config.Config
+	userRepo      user.Repository
+	userRedisRepo user.RedisRepository
+	sessUC        session.UseCase
+	mailer        mailer.Mailer
+	oauthClient   oauth.Client
+	txManager     postgres.TxManager
+}
+
+// User UseCase constructor
+func NewUserUseCase(cfg *config.Config, userRepo user.Repository, userRedisRepo user.RedisRepository, sessUC session.UseCase, mailer mailer.Mailer, oauthClient oauth.Client, txManager postgres.TxManager) user.UseCase {
+	return &userUC{cfg: cfg, userRepo: userRepo, userRedisRepo: userRedisRepo, sessUC: sessUC, mailer: mailer, oauthClient: oauthClient, txManager: txManager}
+}
+
+// Save a new user, an email or username in use is ErrUserTaken
+func (uc *userUC) Register(ctx context.Context, userModel *models.User) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.Register")
+	defer span.End()
+
+	if userModel.Role == "" {
+		userModel.Role = models.RoleUser
+	}
+	err := uc.userRepo.SaveOne(ctx, userModel)
+	if postgres.IsUniqueViolation(err) {
+		return user.ErrUserTaken
+	}
+	if err != nil {
+		return err
+	}
+	registrationsTotal.WithLabelValues("password").Inc()
+	return nil
+}
+
+func (uc *userUC) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.GetByUsername")
+	defer span.End()
+
+	userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{Username: username})
+	if postgres.IsRecordNotFoundError(err) {
+		return nil, user.ErrProfileNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &userModel, nil
+}
+
+// Change the role of a user, their sessions are revoked so the new role applies right away
+func (uc *userUC) SetRole(ctx context.Context, username, role string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.SetRole")
+	defer span.End()
+
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return nil, user.ErrInvalidRole
+	}
+	userModel, err := uc.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err = uc.userRepo.Update(ctx, models.User{ID: userModel.ID, Role: role}); err != nil {
+		return nil, err
+	}
+	userModel.Role = role
+	return userModel, uc.sessUC.DeleteByUserID(ctx, userModel.ID, "")
+}
+
+// Set a password without knowing the current one and revoke all sessions of the user
+func (uc *userUC) SetPassword(ctx context.Context, username, password string) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.SetPassword")
+	defer span.End()
+
+	userModel, err := uc.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if err = uc.updatePassword(ctx, userModel, password); err != nil {
+		return err
+	}
+	return uc.sessUC.DeleteByUserID(ctx, userModel.ID, "")
+}
+
+func (uc *userUC) RevokeSessions(ctx context.Context, username string) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.RevokeSessions")
+	defer span.End()
+
+	userModel, err := uc.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	return uc.sessUC.DeleteByUserID(ctx, userModel.ID, "")
+}
+
+func (uc *userUC) Export(ctx context.Context, username string) (*user.Export, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.Export")
+	defer span.End()
+
+	userModel, err := uc.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	export := &user.Export{User: *userModel}
+	if export.Followings, err = uc.userRepo.GetFollowingsByUser(ctx, userModel.ID); err != nil {
+		return nil, err
+	}
+	if export.Identities, err = uc.userRepo.FindIdentitiesByUser(ctx, userModel.ID); err != nil {
+		return nil, err
+	}
+	if export.AccessTokens, err = uc.userRepo.FindAccessTokens(ctx, userModel.ID); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// followerID follows the user of username, returns the followed user
+func (uc *userUC) Follow(ctx context.Context, username string, followerID uint) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.Follow")
+	defer span.End()
+
+	var userModel *models.User
+	err := uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if userModel, err = uc.GetByUsername(ctx, username); err != nil {
+			return err
+		}
+		return uc.userRepo.SetUserFollow(ctx, userModel.ID, followerID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	followsTotal.WithLabelValues("follow").Inc()
+	return userModel, nil
+}
+
+func (uc *userUC) Unfollow(ctx context.Context, username string, followerID uint) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.Unfollow")
+	defer span.End()
+
+	var userModel *models.User
+	err := uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if userModel, err = uc.GetByUsername(ctx, username); err != nil {
+			return err
+		}
+		return uc.userRepo.RemoveUserFollow(ctx, userModel.ID, followerID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	followsTotal.WithLabelValues("unfollow").Inc()
+	return userModel, nil
+}
+
+// Check the credentials of a login attempt. Failures are counted per email and per client ip in a
+// sliding window, repeated failures are slowed down and eventually locked out for a while.
+func (uc *userUC) Login(ctx context.Context, email, password, clientIP string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.Login")
+	defer span.End()
+
+	userModel, err := uc.login(ctx, email, password, clientIP)
+	loginsTotal.WithLabelValues("password", loginResult(err)).Inc()
+	return userModel, err
+}
+
+func (uc *userUC) login(ctx context.Context, email, password, clientIP string) (*models.User, error) {
+	throttle := uc.cfg.LoginThrottle
+	emailKey := "email:" + strings.ToLower(email)
+	ipKey := "ip:" + clientIP
+
+	for _, key := range []string{emailKey, ipKey} {
+		retryAfter, err := uc.userRedisRepo.GetLockout(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if retryAfter > 0 {
+			return nil, user.NewLockedError(retryAfter)
+		}
+	}
+
+	failures, err := uc.userRedisRepo.CountLoginFailures(ctx, emailKey, throttle.Window)
+	if err != nil {
+		return nil, err
+	}
+	if err = sleepContext(ctx, loginDelay(throttle, failures)); err != nil {
+		return nil, err
+	}
+
+	userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{Email: email})
+	if err == nil && userModel.CheckPassword(password) == nil {
+		if err = uc.userRedisRepo.ResetLoginFailures(ctx, emailKey); err != nil {
+			return nil, err
+		}
+		return &userModel, nil
+	}
+
+	emailFailures, err := uc.userRedisRepo.AddLoginFailure(ctx, emailKey, throttle.Window)
+	if err != nil {
+		return nil, err
+	}
+	ipFailures, err := uc.userRedisRepo.AddLoginFailure(ctx, ipKey, throttle.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	lockout := time.Duration(throttle.LockoutDuration) * time.Second
+	if throttle.MaxIPAttempts > 0 && ipFailures >= throttle.MaxIPAttempts {
+		if err = uc.userRedisRepo.SetLockout(ctx, ipKey, throttle.LockoutDuration); err != nil {
+			return nil, err
+		}
+		logger.FromContext(ctx).Warnf("user.Login IP locked out: %s", clientIP)
+		if err = uc.recordLockout(ctx, userModel.ID, clientIP, lockout); err != nil {
+			return nil, err
+		}
+		return nil, user.NewLockedError(lockout)
+	}
+	if throttle.MaxAttempts > 0 && emailFailures >= throttle.MaxAttempts {
+		if err = uc.userRedisRepo.SetLockout(ctx, emailKey, throttle.LockoutDuration); err != nil {
+			return nil, err
+		}
+		logger.FromContext(ctx).Warnf("user.Login Account locked out: %s", email)
+		if err = uc.recordLockout(ctx, userModel.ID, clientIP, lockout); err != nil {
+			return nil, err
+		}
+		return nil, user.NewLockedError(lockout)
+	}
+	return nil, user.ErrInvalidCredentials
+}
+
+// Keep the lockout of a login to an existing account, so its owner is told and UnlockAccount can lift it
+func (uc *userUC) recordLockout(ctx context.Context, userID uint, clientIP string, lockout time.Duration) error {
+	if userID == 0 {
+		return nil
+	}
+	return uc.userRepo.CreateLoginLockout(ctx, &models.LoginLockout{
+		UserID:      userID,
+		IP:          clientIP,
+		LockedUntil: time.Now().Add(lockout),
+	})
+}
+
+// Get the lockouts the owner hasn't seen yet, call it once the login is complete
+func (uc *userUC) PopLoginLockouts(ctx context.Context, userID uint) ([]models.LoginLockout, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.PopLoginLockouts")
+	defer span.End()
+
+	return uc.userRepo.PopUnseenLoginLockouts(ctx, userID)
+}
+
+// Lift the login lockout of an account and forget its failed logins, the addresses
+// logins to it were locked out from are unlocked too
+func (uc *userUC) UnlockAccount(ctx context.Context, username string) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.UnlockAccount")
+	defer span.End()
+
+	userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{Username: username})
+	if postgres.IsRecordNotFoundError(err) {
+		return user.ErrProfileNotFound
+	} else if err != nil {
+		return err
+	}
+	if err = uc.userRedisRepo.ResetLoginFailures(ctx, "email:"+strings.ToLower(userModel.Email)); err != nil {
+		return err
+	}
+	// the addresses logins to the account were locked out from
+	lockouts, err := uc.userRepo.FindActiveLoginLockouts(ctx, userModel.ID)
+	if err != nil {
+		return err
+	}
+	for _, lockout := range lockouts {
+		if err = uc.userRedisRepo.ResetLoginFailures(ctx, "ip:"+lockout.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Issue a short lived challenge for a user who passed the password check but still needs a second factor
+func (uc *userUC) CreateLoginChallenge(ctx context.Context, userModel *models.User) (string, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.CreateLoginChallenge")
+	defer span.End()
+
+	challenge, err := utils.GenerateSecureToken(challengeTokenSize)
+	if err != nil {
+		return "", err
+	}
+	if err = uc.userRedisRepo.SetLoginChallenge(ctx, utils.HashToken(challenge), userModel.ID, uc.cfg.TwoFactor.ChallengeExpire); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// Complete a two factor login with a totp or recovery code, the challenge is dropped after too many wrong codes
+func (uc *userUC) VerifyLoginChallenge(ctx context.Context, challenge, code string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.VerifyLoginChallenge")
+	defer span.End()
+
+	challengeHash := utils.HashToken(challenge)
+	userID, err := uc.userRedisRepo.GetLoginChallenge(ctx, challengeHash)
+	if err != nil {
+		return nil, user.ErrInvalidChallenge
+	}
+	userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{ID: userID})
+	if err != nil {
+		return nil, user.ErrInvalidChallenge
+	}
+
+	ok, err := uc.verifySecondFactor(ctx, &userModel, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		attempts, err := uc.userRedisRepo.IncrLoginChallengeAttempts(ctx, challengeHash)
+		if err != nil {
+			return nil, err
+		}
+		if attempts >= uc.cfg.TwoFactor.ChallengeAttempts {
+			if err = uc.userRedisRepo.DeleteLoginChallenge(ctx, challengeHash); err != nil {
+				return nil, err
+			}
+			return nil, user.ErrInvalidChallenge
+		}
+		return nil, user.ErrInvalidTotpCode
+	}
+
+	if err = uc.userRedisRepo.DeleteLoginChallenge(ctx, challengeHash); err != nil {
+		return nil, err
+	}
+	return &userModel, nil
+}
+
+// Start the totp enrollment, returns the otpauth uri to show as a qr code.
+// The secret stays inactive until it's confirmed with a first code.
+func (uc *userUC) EnrollTotp(ctx context.Context, userModel *models.User) (string, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.EnrollTotp")
+	defer span.End()
+
+	if userModel.TotpEnabled {
+		return "", user.ErrTotpEnabled
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      uc.cfg.TwoFactor.Issuer,
+		AccountName: userModel.Email,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err = uc.userRepo.UpdateTotp(ctx, userModel.ID, key.Secret(), false); err != nil {
+		return "", err
+	}
+	return key.URL(), nil
+}
+
+// Enable totp with a first valid code and hand out the recovery codes
+func (uc *userUC) ConfirmTotp(ctx context.Context, userModel *models.User, code string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.ConfirmTotp")
+	defer span.End()
+
+	if userModel.TotpEnabled {
+		return nil, user.ErrTotpEnabled
+	}
+	if userModel.TotpSecret == "" {
+		return nil, user.ErrTotpNotEnrolled
+	}
+	if !totp.Validate(code, userModel.TotpSecret) {
+		return nil, user.ErrInvalidTotpCode
+	}
+	if _, err := uc.userRedisRepo.MarkTotpCodeUsed(ctx, userModel.ID, code, totpCodeExpire); err != nil {
+		return nil, err
+	}
+
+	if err := uc.userRepo.UpdateTotp(ctx, userModel.ID, userModel.TotpSecret, true); err != nil {
+		return nil, err
+	}
+	return uc.replaceRecoveryCodes(ctx, userModel.ID)
+}
+
+// Turn off totp after checking the password, the secret and recovery codes are dropped
+func (uc *userUC) DisableTotp(ctx context.Context, userModel *models.User, password string) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.DisableTotp")
+	defer span.End()
+
+	if userModel.CheckPassword(password) != nil {
+		return user.ErrInvalidPassword
+	}
+	if !userModel.TotpEnabled {
+		return user.ErrTotpNotEnabled
+	}
+	if err := uc.userRepo.UpdateTotp(ctx, userModel.ID, "", false); err != nil {
+		return err
+	}
+	return uc.userRepo.ReplaceRecoveryCodes(ctx, userModel.ID, nil)
+}
+
+// Replace all recovery codes after checking the password
+func (uc *userUC) RegenerateRecoveryCodes(ctx context.Context, userModel *models.User, password string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.RegenerateRecoveryCodes")
+	defer span.End()
+
+	if userModel.CheckPassword(password) != nil {
+		return nil, user.ErrInvalidPassword
+	}
+	if !userModel.TotpEnabled {
+		return nil, user.ErrTotpNotEnabled
+	}
+	return uc.replaceRecoveryCodes(ctx, userModel.ID)
+}
+
+// A six digit code is checked as totp, anything else as a recovery code
+func (uc *userUC) verifySecondFactor(ctx context.Context, userModel *models.User, code string) (bool, error) {
+	if !userModel.TotpEnabled {
+		return false, nil
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == 6 {
+		if !totp.Validate(code, userModel.TotpSecret) {
+			return false, nil
+		}
+		return uc.userRedisRepo.MarkTotpCodeUsed(ctx, userModel.ID, code, totpCodeExpire)
+	}
+	return uc.userRepo.UseRecoveryCode(ctx, userModel.ID, utils.HashToken(normalizeRecoveryCode(code)))
+}
+
+func (uc *userUC) replaceRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	codes := make([]string, uc.cfg.TwoFactor.RecoveryCodes)
+	codeHashes := make([]string, len(codes))
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		codeHashes[i] = utils.HashToken(normalizeRecoveryCode(code))
+	}
+	if err := uc.userRepo.ReplaceRecoveryCodes(ctx, userID, codeHashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Create a named access token, the plain token is only returned here
+func (uc *userUC) CreateAccessToken(ctx context.Context, userID uint, name string, scopes []string, expiresAt *time.Time) (string, *models.PersonalAccessToken, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.CreateAccessToken")
+	defer span.End()
+
+	secret, err := utils.GenerateSecureToken(accessTokenSize)
+	if err != nil {
+		return "", nil, err
+	}
+	token := models.AccessTokenPrefix + secret
+	tokenModel := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: utils.HashToken(token),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+	}
+	if err = uc.userRepo.CreateAccessToken(ctx, tokenModel); err != nil {
+		return "", nil, err
+	}
+	return token, tokenModel, nil
+}
+
+func (uc *userUC) GetAccessTokens(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.GetAccessTokens")
+	defer span.End()
+
+	return uc.userRepo.FindAccessTokens(ctx, userID)
+}
+
+func (uc *userUC) RevokeAccessToken(ctx context.Context, userID, tokenID uint) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.RevokeAccessToken")
+	defer span.End()
+
+	deleted, err := uc.userRepo.DeleteAccessToken(ctx, userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return user.ErrAccessTokenMissing
+	}
+	return nil
+}
+
+// Look up an access token presented by a client and track its last use
+func (uc *userUC) AuthenticateAccessToken(ctx context.Context, token string) (*models.PersonalAccessToken, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.AuthenticateAccessToken")
+	defer span.End()
+
+	tokenModel, err := uc.userRepo.FindAccessTokenByHash(ctx, utils.HashToken(token))
+	if err != nil || tokenModel.IsExpired() {
+		return nil, user.ErrInvalidAccessToken
+	}
+
+	now := time.Now()
+	if tokenModel.LastUsedAt == nil || now.Sub(*tokenModel.LastUsedAt) > accessTokenTouchInterval {
+		if err = uc.userRepo.TouchAccessToken(ctx, tokenModel.ID, now); err != nil {
+			return nil, err
+		}
+		tokenModel.LastUsedAt = &now
+	}
+	return &tokenModel, nil
+}
+
+// Start an authorization code flow, returns the provider url to redirect the user to
+func (uc *userUC) OAuthAuthorize(ctx context.Context, provider string) (string, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.OAuthAuthorize")
+	defer span.End()
+
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := utils.GenerateSecureToken(oauthSecretSize)
+		if err != nil {
+			return "", err
+		}
+		secrets[i] = secret
+	}
+	state := &models.OAuthState{Provider: provider, Nonce: secrets[1], Verifier: secrets[2]}
+
+	authURL, err := uc.oauthClient.AuthCodeURL(ctx, provider, secrets[0], state.Nonce, state.Verifier)
+	if errors.Is(err, oauth.ErrUnknownProvider) {
+		return "", user.ErrUnknownProvider
+	} else if err != nil {
+		return "", apperrors.Unavailable("provider", err)
+	}
+	if err = uc.userRedisRepo.SetOAuthState(ctx, secrets[0], state, uc.cfg.OAuth.StateExpire); err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+// Finish an authorization code flow. A known identity logs its user in, otherwise the identity is
+// linked to the user with the same verified email, or to a new user when there is none.
+func (uc *userUC) OAuthCallback(ctx context.Context, provider, state, code string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "user.usecase.OAuthCallback")
+	defer span.End()
+
+	userModel, err := uc.oauthCallback(ctx, provider, state, code)
+	loginsTotal.WithLabelValues("oauth", loginResult(err)).Inc()
+	return userModel, err
+}
+
+func (uc *userUC) oauthCallback(ctx context.Context, provider, state, code string) (*models.User, error) {
+	pending, err := uc.userRedisRepo.PopOAuthState(ctx, state)
+	if err != nil || pending.Provider != provider {
+		return nil, user.ErrInvalidOAuthState
+	}
+	identity, err := uc.oauthClient.Exchange(ctx, provider, code, pending.Nonce, pending.Verifier)
+	if err != nil {
+		return nil, apperrors.Unauthorized("provider", err)
+	}
+
+	identityModel, err := uc.userRepo.FindIdentity(ctx, provider, identity.Subject)
+	if err == nil {
+		userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{ID: identityModel.UserID})
+		if err != nil {
+			return nil, err
+		}
+		return &userModel, nil
+	} else if !postgres.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	// only a verified email proves the identity belongs to the account
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, user.ErrUnverifiedEmail
+	}
+	userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{Email: identity.Email})
+	if postgres.IsRecordNotFoundError(err) {
+		userModel, err = uc.createOAuthUser(ctx, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = uc.userRepo.CreateIdentity(ctx, &models.UserIdentity{
+		UserID:   userModel.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}); err != nil {
+		return nil, err
+	}
+	return &userModel, nil
+}
+
+// Users signing up through a provider get a random password, they can set one with a password reset
+func (uc *userUC) createOAuthUser(ctx context.Context, identity *oauth.Identity) (models.User, error) {
+	userModel := models.User{Email: identity.Email, Role: models.RoleUser}
+	password, err := utils.GenerateSecureToken(oauthSecretSize)
+	if err != nil {
+		return userModel, err
+	}
+	if err = userModel.SetPassword(password); err != nil {
+		return userModel, err
+	}
+
+	base := identity.PreferredUsername
+	if base == "" {
+		base = strings.Split(identity.Email, "@")[0]
+	}
+	base = sanitizeUsername(base)
+	userModel.Username = base
+	for i := 0; i < oauthUsernameTries; i++ {
+		if _, err = uc.userRepo.FindOneUser(ctx, &models.User{Username: userModel.Username}); postgres.IsRecordNotFoundError(err) {
+			break
+		} else if err != nil {
+			return userModel, err
+		}
+		suffix, err := utils.GenerateSecureToken(3)
+		if err != nil {
+			return userModel, err
+		}
+		userModel.Username = base + "-" + strings.ToLower(sanitizeUsername(suffix))
+	}
+
+	if err = uc.userRepo.SaveOne(ctx, &userModel); err != nil {
+		return userModel, err
+	}
+	registrationsTotal.WithLabelValues("oauth").Inc()
+	return userModel, nil
+}
+
+// Mail a single use reset token to the user, unknown emails are ignored so accounts can't be enumerated
+func (uc *userUC) ForgotPassword(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.ForgotPassword")
+	defer span.End()
+
+	userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{Email: email})
+	// unknown emails look like known ones, so the answer doesn't tell who is registered
+	if postgres.IsRecordNotFoundError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateSecureToken(resetTokenSize)
+	if err != nil {
+		return err
+	}
+	if err = uc.userRedisRepo.SetResetToken(ctx, utils.HashToken(token), userModel.ID, uc.cfg.PasswordReset.Expire); err != nil {
+		return err
+	}
+
+	resetURL, err := url.Parse(uc.cfg.PasswordReset.URL)
+	if err != nil {
+		return err
+	}
+	query := resetURL.Query()
+	query.Set("token", token)
+	resetURL.RawQuery = query.Encode()
+
+	body := fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password, it expires in %s.\n\n%s\n\nIf you didn't ask for a password reset you can ignore this email.\n",
+		userModel.Username,
+		time.Duration(uc.cfg.PasswordReset.Expire)*time.Second,
+		resetURL.String(),
+	)
+	if err = uc.mailer.Send(ctx, userModel.Email, "Reset your password", body); err != nil {
+		return apperrors.Unavailable("password", err)
+	}
+	return nil
+}
+
+// Set a new password with a reset token and revoke all sessions of the user
+func (uc *userUC) ResetPassword(ctx context.Context, token, password string) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.ResetPassword")
+	defer span.End()
+
+	userID, err := uc.userRedisRepo.PopResetToken(ctx, utils.HashToken(token))
+	if err != nil {
+		return user.ErrInvalidResetToken
+	}
+	userModel, err := uc.userRepo.FindOneUser(ctx, &models.User{ID: userID})
+	if err != nil {
+		return user.ErrInvalidResetToken
+	}
+
+	if err = uc.updatePassword(ctx, &userModel, password); err != nil {
+		return err
+	}
+	return uc.sessUC.DeleteByUserID(ctx, userModel.ID, "")
+}
+
+// Set a new password after checking the current one and revoke all other sessions of the user
+func (uc *userUC) ChangePassword(ctx context.Context, userModel *models.User, sessionID, currentPassword, password string) error {
+	ctx, span := tracing.Start(ctx, "user.usecase.ChangePassword")
+	defer span.End()
+
+	if userModel.CheckPassword(currentPassword) != nil {
+		return user.ErrInvalidPassword
+	}
+
+	if err := uc.updatePassword(ctx, userModel, password); err != nil {
+		return err
+	}
+	return uc.sessUC.DeleteByUserID(ctx, userModel.ID, sessionID)
+}
+
+func (uc *userUC) updatePassword(ctx context.Context, userModel *models.User, password string) error {
+	if err := userModel.SetPassword(password); err != nil {
+		return err
+	}
+	return uc.userRepo.Update(ctx, models.User{ID: userModel.ID, PasswordHash: userModel.PasswordHash})
+}
+
+// Delay doubles for every failure past DelayAfter, up to MaxDelay
+func loginDelay(throttle config.LoginThrottle, failures int) time.Duration {
+	if throttle.BaseDelay <= 0 || failures < throttle.DelayAfter {
+		return 0
+	}
+	shift := failures - throttle.DelayAfter
+	if shift > maxDelayShift {
+		shift = maxDelayShift
+	}
+	delay := time.Duration(throttle.BaseDelay<<shift) * time.Millisecond
+	if max := time.Duration(throttle.MaxDelay) * time.Millisecond; throttle.MaxDelay > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recovery codes look like "abcd-efgh" so they're easy to type
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(bytes))
+	return code[:4] + "-" + code[4:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// Keep letters, digits, '-' and '_', and pad short names to the minimum length of 4
+func sanitizeUsername(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			sb.WriteRune(r)
+		}
+	}
+	username := sb.String()
+	for len(username) < 4 {
+		username += "_"
+	}
+	return username
+}
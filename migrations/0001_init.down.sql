@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS comment_models;
+DROP TABLE IF EXISTS favorite_models;
+DROP TABLE IF EXISTS article_tags;
+DROP TABLE IF EXISTS tag_models;
+DROP TABLE IF EXISTS article_models;
+DROP TABLE IF EXISTS article_user_models;
+DROP TABLE IF EXISTS user_identity_models;
+DROP TABLE IF EXISTS personal_access_token_models;
+DROP TABLE IF EXISTS recovery_code_models;
+DROP TABLE IF EXISTS login_lockout_models;
+DROP TABLE IF EXISTS follow_models;
+DROP TABLE IF EXISTS user_models;
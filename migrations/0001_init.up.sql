@@ -0,0 +1,127 @@
+-- Schema previously created by gorm's AutoMigrate. Tables and indexes keep
+-- gorm's names and are only created when missing, so databases that were
+-- auto migrated adopt this version as they are.
+
+CREATE TABLE IF NOT EXISTS user_models (
+    id serial PRIMARY KEY,
+    username text,
+    email text,
+    bio varchar(1024),
+    image text,
+    password text NOT NULL,
+    role text NOT NULL DEFAULT 'user',
+    totp_secret text,
+    totp_enabled boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_user_models_email ON user_models (email);
+
+CREATE TABLE IF NOT EXISTS follow_models (
+    id serial PRIMARY KEY,
+    following_id integer,
+    followed_by_id integer
+);
+
+CREATE TABLE IF NOT EXISTS login_lockout_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    ip text,
+    locked_until timestamp with time zone,
+    seen boolean,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_login_lockout_models_user_id ON login_lockout_models (user_id);
+
+CREATE TABLE IF NOT EXISTS recovery_code_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    code_hash text NOT NULL,
+    used_at timestamp with time zone,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_code_models_user_id ON recovery_code_models (user_id);
+
+CREATE TABLE IF NOT EXISTS personal_access_token_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    name text NOT NULL,
+    token_hash text NOT NULL,
+    scopes text NOT NULL,
+    expires_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_personal_access_token_models_user_id ON personal_access_token_models (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_personal_access_token_models_token_hash ON personal_access_token_models (token_hash);
+
+CREATE TABLE IF NOT EXISTS user_identity_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_user_identity_models_user_id ON user_identity_models (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_provider_subject ON user_identity_models (provider, subject);
+
+CREATE TABLE IF NOT EXISTS article_user_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_article_user_models_deleted_at ON article_user_models (deleted_at);
+
+CREATE TABLE IF NOT EXISTS article_models (
+    id serial PRIMARY KEY,
+    slug text,
+    title text,
+    description varchar(2048),
+    body varchar(2048),
+    author_id integer,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_article_models_slug ON article_models (slug);
+CREATE INDEX IF NOT EXISTS idx_article_models_deleted_at ON article_models (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tag_models (
+    id serial PRIMARY KEY,
+    tag text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_tag_models_tag ON tag_models (tag);
+CREATE INDEX IF NOT EXISTS idx_tag_models_deleted_at ON tag_models (deleted_at);
+
+CREATE TABLE IF NOT EXISTS article_tags (
+    article_id integer,
+    tag_id integer,
+    PRIMARY KEY (article_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS favorite_models (
+    id serial PRIMARY KEY,
+    favorite_id integer,
+    favorite_by_id integer,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_favorite_models_deleted_at ON favorite_models (deleted_at);
+
+CREATE TABLE IF NOT EXISTS comment_models (
+    id serial PRIMARY KEY,
+    article_id integer,
+    author_id integer,
+    body varchar(2048),
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_comment_models_deleted_at ON comment_models (deleted_at);
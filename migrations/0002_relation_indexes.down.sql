@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_comment_models_article_id;
+DROP INDEX IF EXISTS idx_article_tags_tag_id;
+DROP INDEX IF EXISTS idx_article_models_author_id;
+DROP INDEX IF EXISTS idx_article_user_models_user_id;
+DROP INDEX IF EXISTS idx_favorite_models_favorite_by_id;
+DROP INDEX IF EXISTS uix_favorite_models_pair;
+DROP INDEX IF EXISTS idx_follow_models_followed_by_id;
+DROP INDEX IF EXISTS uix_follow_models_pair;
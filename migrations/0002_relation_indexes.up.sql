@@ -0,0 +1,17 @@
+-- Lookups by the relation columns scanned whole tables, and racing
+-- follow/favorite requests could store the same pair twice.
+
+DELETE FROM follow_models a USING follow_models b
+WHERE a.following_id = b.following_id AND a.followed_by_id = b.followed_by_id AND a.id > b.id;
+CREATE UNIQUE INDEX uix_follow_models_pair ON follow_models (following_id, followed_by_id);
+CREATE INDEX idx_follow_models_followed_by_id ON follow_models (followed_by_id);
+
+DELETE FROM favorite_models a USING favorite_models b
+WHERE a.favorite_id = b.favorite_id AND a.favorite_by_id = b.favorite_by_id AND a.id > b.id;
+CREATE UNIQUE INDEX uix_favorite_models_pair ON favorite_models (favorite_id, favorite_by_id);
+CREATE INDEX idx_favorite_models_favorite_by_id ON favorite_models (favorite_by_id);
+
+CREATE INDEX idx_article_user_models_user_id ON article_user_models (user_id);
+CREATE INDEX idx_article_models_author_id ON article_models (author_id);
+CREATE INDEX idx_article_tags_tag_id ON article_tags (tag_id);
+CREATE INDEX idx_comment_models_article_id ON comment_models (article_id);
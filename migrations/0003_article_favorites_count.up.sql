@@ -0,0 +1,14 @@
+-- Articles keep how often they were favorited instead of counting the
+-- favorites of every article they render.
+
+ALTER TABLE article_models ADD COLUMN favorites_count integer NOT NULL DEFAULT 0;
+
+UPDATE article_models a
+SET favorites_count = f.count
+FROM (
+    SELECT favorite_id, count(*) AS count
+    FROM favorite_models
+    WHERE deleted_at IS NULL
+    GROUP BY favorite_id
+) f
+WHERE f.favorite_id = a.id;
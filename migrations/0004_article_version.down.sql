@@ -0,0 +1 @@
+ALTER TABLE article_models DROP COLUMN version;
@@ -0,0 +1,4 @@
+-- Articles are versioned, so an edit based on an outdated read can be told
+-- apart and rejected instead of overwriting the changes made in between.
+
+ALTER TABLE article_models ADD COLUMN version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS user_identity_models;
+DROP TABLE IF EXISTS personal_access_token_models;
+DROP TABLE IF EXISTS recovery_code_models;
+DROP TABLE IF EXISTS login_lockout_models;
+
+ALTER TABLE user_models DROP COLUMN IF EXISTS totp_enabled;
+ALTER TABLE user_models DROP COLUMN IF EXISTS totp_secret;
+ALTER TABLE user_models DROP COLUMN IF EXISTS role;
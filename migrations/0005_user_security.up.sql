@@ -0,0 +1,50 @@
+-- Roles, two factor auth, login lockouts, access tokens and login providers.
+-- Databases auto migrated before versioned migrations only have the columns of
+-- the first release, the ones created by 0001 since then have everything already.
+
+ALTER TABLE user_models ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user';
+ALTER TABLE user_models ADD COLUMN IF NOT EXISTS totp_secret text;
+ALTER TABLE user_models ADD COLUMN IF NOT EXISTS totp_enabled boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS login_lockout_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    ip text,
+    locked_until timestamp with time zone,
+    seen boolean,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_login_lockout_models_user_id ON login_lockout_models (user_id);
+
+CREATE TABLE IF NOT EXISTS recovery_code_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    code_hash text NOT NULL,
+    used_at timestamp with time zone,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_code_models_user_id ON recovery_code_models (user_id);
+
+CREATE TABLE IF NOT EXISTS personal_access_token_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    name text NOT NULL,
+    token_hash text NOT NULL,
+    scopes text NOT NULL,
+    expires_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_personal_access_token_models_user_id ON personal_access_token_models (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uix_personal_access_token_models_token_hash ON personal_access_token_models (token_hash);
+
+CREATE TABLE IF NOT EXISTS user_identity_models (
+    id serial PRIMARY KEY,
+    user_id integer,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text,
+    created_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_user_identity_models_user_id ON user_identity_models (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_provider_subject ON user_identity_models (provider, subject);
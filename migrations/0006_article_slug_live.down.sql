@@ -0,0 +1,3 @@
+-- Fails while a deleted article shares its slug with another article
+DROP INDEX IF EXISTS uix_article_models_slug;
+CREATE UNIQUE INDEX uix_article_models_slug ON article_models (slug);
@@ -0,0 +1,5 @@
+-- Deleted articles are soft deleted and keep their slug, only articles that
+-- aren't deleted need a unique one so a deleted title can be used again.
+
+DROP INDEX IF EXISTS uix_article_models_slug;
+CREATE UNIQUE INDEX uix_article_models_slug ON article_models (slug) WHERE deleted_at IS NULL;
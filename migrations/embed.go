@@ -0,0 +1,8 @@
+// Versioned schema migrations of the api, applied with "api migrate up".
+// New ones are created with "api migrate create <name>".
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,123 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// What went wrong, decides the http status an error is rendered with
+type Kind uint8
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindForbidden
+	KindUnauthorized
+	KindValidation
+	KindTooManyRequests
+	KindUnavailable
+	KindTimeout
+	KindPreconditionFailed
+	KindPreconditionRequired
+)
+
+var kindStatus = map[Kind]int{
+	KindInternal:             http.StatusInternalServerError,
+	KindNotFound:             http.StatusNotFound,
+	KindConflict:             http.StatusConflict,
+	KindForbidden:            http.StatusForbidden,
+	KindUnauthorized:         http.StatusUnauthorized,
+	KindValidation:           http.StatusUnprocessableEntity,
+	KindTooManyRequests:      http.StatusTooManyRequests,
+	KindUnavailable:          http.StatusServiceUnavailable,
+	KindTimeout:              http.StatusGatewayTimeout,
+	KindPreconditionFailed:   http.StatusPreconditionFailed,
+	KindPreconditionRequired: http.StatusPreconditionRequired,
+}
+
+// Domain error returned by usecases, Key is the field of the RealWorld errors object
+type Error struct {
+	Kind       Kind
+	Key        string
+	Err        error
+	RetryAfter time.Duration // only set for KindTooManyRequests
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Http status of the kind
+func (e *Error) Status() int {
+	return kindStatus[e.Kind]
+}
+
+func NotFound(key string, err error) *Error {
+	return &Error{Kind: KindNotFound, Key: key, Err: err}
+}
+
+func Conflict(key string, err error) *Error {
+	return &Error{Kind: KindConflict, Key: key, Err: err}
+}
+
+func Forbidden(key string, err error) *Error {
+	return &Error{Kind: KindForbidden, Key: key, Err: err}
+}
+
+func Unauthorized(key string, err error) *Error {
+	return &Error{Kind: KindUnauthorized, Key: key, Err: err}
+}
+
+// err may be validator.ValidationErrors, they're rendered field by field
+func Validation(key string, err error) *Error {
+	return &Error{Kind: KindValidation, Key: key, Err: err}
+}
+
+func TooManyRequests(key string, err error, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindTooManyRequests, Key: key, Err: err, RetryAfter: retryAfter}
+}
+
+func Unavailable(key string, err error) *Error {
+	return &Error{Kind: KindUnavailable, Key: key, Err: err}
+}
+
+func Timeout(key string, err error) *Error {
+	return &Error{Kind: KindTimeout, Key: key, Err: err}
+}
+
+// A conditional request whose precondition, like If-Match, doesn't hold anymore
+func PreconditionFailed(key string, err error) *Error {
+	return &Error{Kind: KindPreconditionFailed, Key: key, Err: err}
+}
+
+// A request that has to be conditional came without its precondition
+func PreconditionRequired(key string, err error) *Error {
+	return &Error{Kind: KindPreconditionRequired, Key: key, Err: err}
+}
+
+// Domain error in the chain of err, errors without one are internal
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// Kind of err, KindInternal when it isn't a domain error
+func KindOf(err error) Kind {
+	if appErr, ok := As(err); ok {
+		return appErr.Kind
+	}
+	return KindInternal
+}
+
+func IsNotFound(err error) bool {
+	return KindOf(err) == KindNotFound
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Postgres accepts at most 65535 parameters in one statement
+const (
+	maxBulkParams = 65535
+	maxBulkRows   = 1000
+)
+
+// Insert rows with multi row INSERT statements, suffix is appended to each of them (e.g. ON CONFLICT ...)
+func BulkInsert(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]interface{}, suffix string) error {
+	return bulkInsert(ctx, tx, table, columns, rows, suffix, nil)
+}
+
+// Like BulkInsert, returns the ids of the inserted rows in the order of rows. A suffix
+// skipping conflicting rows would misalign them, update conflicting rows instead.
+func BulkInsertReturningIDs(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]interface{}, suffix string) ([]uint, error) {
+	ids := make([]uint, 0, len(rows))
+	err := bulkInsert(ctx, tx, table, columns, rows, suffix+" RETURNING id", func(result *sql.Rows) error {
+		for result.Next() {
+			var id uint
+			if err := result.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return result.Err()
+	})
+	if err == nil && len(ids) != len(rows) {
+		err = fmt.Errorf("bulk insert into %s returned %d ids for %d rows", table, len(ids), len(rows))
+	}
+	return ids, err
+}
+
+func bulkInsert(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]interface{}, suffix string, scan func(*sql.Rows) error) error {
+	batchSize := maxBulkParams / len(columns)
+	if batchSize > maxBulkRows {
+		batchSize = maxBulkRows
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		query, args := bulkInsertQuery(table, columns, rows[start:end], suffix)
+		if scan == nil {
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("bulk insert into %s: %w", table, err)
+			}
+			continue
+		}
+		result, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("bulk insert into %s: %w", table, err)
+		}
+		err = scan(result)
+		result.Close()
+		if err != nil {
+			return fmt.Errorf("bulk insert into %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func bulkInsertQuery(table string, columns []string, rows [][]interface{}, suffix string) (string, []interface{}) {
+	var query strings.Builder
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	for i, row := range rows {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteByte('(')
+		for j, value := range row {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			args = append(args, value)
+			fmt.Fprintf(&query, "$%d", len(args))
+		}
+		query.WriteByte(')')
+	}
+	if suffix != "" {
+		query.WriteByte(' ')
+		query.WriteString(suffix)
+	}
+	return query.String(), args
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+)
+
+// gorm v1 runs every statement without a context, ctxConn binds one to the
+// connection pool so queries and transactions are cancelled with the request
+type ctxConn struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func (c ctxConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) Prepare(query string) (*sql.Stmt, error) {
+	return c.db.PrepareContext(c.ctx, query)
+}
+
+func (c ctxConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+// A transaction is rolled back by database/sql once ctx is done
+func (c ctxConn) Begin() (*sql.Tx, error) {
+	return c.db.BeginTx(c.ctx, nil)
+}
+
+func (c ctxConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.db.BeginTx(ctx, opts)
+}
+
+// DB whose statements stop when ctx is cancelled or its deadline passes. Within
+// RunInTransaction it's the transaction of ctx, which already carries the context
+// it began with
+func WithContext(ctx context.Context, db *DB) *DB {
+	if ctx == nil {
+		return db
+	}
+	// the callbacks of instrument start the spans of statements from ctx
+	if tx, ok := ctx.Value(txKey{}).(*DB); ok {
+		return tx.Set(ctxSetting, ctx)
+	}
+	sqlDB, ok := db.CommonDB().(*sql.DB)
+	if !ok || ctx.Done() == nil {
+		return db.Set(ctxSetting, ctx)
+	}
+	scoped, err := gorm.Open("postgres", ctxConn{ctx: ctx, db: sqlDB})
+	if err != nil {
+		return db
+	}
+	if gormLog != nil {
+		scoped.SetLogger(gormLog)
+	}
+	scoped.LogMode(logMode)
+	return scoped.Set(ctxSetting, ctx)
+}
@@ -1,10 +1,15 @@
 package postgres
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/lib/pq"
 )
 
 type DB = gorm.DB
@@ -12,17 +17,54 @@ type DB = gorm.DB
 // in memory DB instance
 var instance *DB
 
-func DBInit(postgresUrl string) (*DB, error) {
+// logging of the instance, copied onto the DBs of WithContext
+var (
+	gormLog *gormLogger
+	logMode bool
+)
+
+// Connection url of the configured database
+func PostgresURL(cfg *config.Config) string {
+	sslMode := "disable"
+	if cfg.Postgres.PostgresqlSSLMode {
+		sslMode = "enable"
+	}
+	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=%s",
+		cfg.Postgres.PostgresqlUser,
+		cfg.Postgres.PostgresqlPassword,
+		cfg.Postgres.PostgresqlHost,
+		cfg.Postgres.PostgresqlPort,
+		cfg.Postgres.PostgresqlDbname,
+		sslMode,
+	)
+}
+
+// Statements slower than slowQuery are logged as warnings, zero doesn't log any
+func DBInit(postgresUrl string, logger logger.Logger, debug bool, slowQuery time.Duration) (*DB, error) {
 	db, err := gorm.Open("postgres", postgresUrl)
 	if err != nil {
-		fmt.Println("db err: (Init) ", err)
+		return nil, err
 	}
 	db.DB().SetMaxIdleConns(10)
-	//db.LogMode(true)
+	gormLog, logMode = &gormLogger{logger: logger}, debug
+	db.SetLogger(gormLog)
+	db.LogMode(logMode)
+	instrument(db, logger, slowQuery)
 	instance = db
-	return db, err
+	return db, nil
 }
 
 func GetDB() *DB {
 	return instance
 }
+
+// Tells a missing record apart from a failed query
+func IsRecordNotFoundError(err error) bool {
+	return gorm.IsRecordNotFoundError(err)
+}
+
+// Tells a unique constraint violation apart from a failed query
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+)
+
+// Routes gorm's log output to the app logger, queries are logged at debug level
+type gormLogger struct {
+	logger logger.Logger
+}
+
+func (g *gormLogger) Print(values ...interface{}) {
+	if len(values) < 2 {
+		return
+	}
+	if values[0] == "sql" && len(values) >= 6 {
+		g.logger.With("source", values[1], "duration", values[2], "rows", values[5]).Debugf("%v %v", values[3], values[4])
+		return
+	}
+	g.logger.With("source", values[1]).Error(values[2:]...)
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// gorm setting carrying the context of WithContext to the callbacks
+const ctxSetting = "instrumentation:context"
+
+// instance settings of the statement running
+const (
+	spanSetting  = "instrumentation:span"
+	startSetting = "instrumentation:start"
+)
+
+var queryDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "How long SQL statements took, partitioned by operation, table and normalized statement.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	},
+	[]string{"operation", "table", "statement"},
+)
+
+func init() {
+	prometheus.MustRegister(queryDuration)
+}
+
+var (
+	instrumentOnce     sync.Once
+	slowQueryThreshold time.Duration
+)
+
+// Give every statement gorm runs through its callbacks a child span of the
+// request, a duration observation and a warning when it's slower than slowQuery.
+// Callbacks are registered on gorm's defaults so the DBs of WithContext share them.
+func instrument(db *DB, logger logger.Logger, slowQuery time.Duration) {
+	instrumentOnce.Do(func() {
+		slowQueryThreshold = slowQuery
+		callback := gorm.DefaultCallback
+		callback.Create().Before("gorm:create").Register("instrumentation:before_create", beforeStatement)
+		callback.Create().After("gorm:create").Register("instrumentation:after_create", afterStatement("INSERT"))
+		callback.Query().Before("gorm:query").Register("instrumentation:before_query", beforeStatement)
+		callback.Query().After("gorm:query").Register("instrumentation:after_query", afterStatement("SELECT"))
+		callback.Update().Before("gorm:update").Register("instrumentation:before_update", beforeStatement)
+		callback.Update().After("gorm:update").Register("instrumentation:after_update", afterStatement("UPDATE"))
+		callback.Delete().Before("gorm:delete").Register("instrumentation:before_delete", beforeStatement)
+		callback.Delete().After("gorm:delete").Register("instrumentation:after_delete", afterStatement("DELETE"))
+		callback.RowQuery().Before("gorm:row_query").Register("instrumentation:before_row_query", beforeStatement)
+		callback.RowQuery().After("gorm:row_query").Register("instrumentation:after_row_query", afterStatement("SELECT"))
+	})
+	// the collector of a second DB of the process, like the admin cli's, is already there
+	if err := prometheus.Register(collectors.NewDBStatsCollector(db.DB(), "postgres")); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			logger.Errorf("postgres pool metrics: %v", err)
+		}
+	}
+}
+
+func scopeContext(scope *gorm.Scope) context.Context {
+	if ctx, ok := scope.Get(ctxSetting); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+func beforeStatement(scope *gorm.Scope) {
+	_, span := tracing.Start(scopeContext(scope), "db.query", trace.WithSpanKind(trace.SpanKindClient))
+	scope.InstanceSet(spanSetting, span)
+	scope.InstanceSet(startSetting, time.Now())
+}
+
+func afterStatement(operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		value, ok := scope.InstanceGet(spanSetting)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		defer span.End()
+		start, _ := scope.InstanceGet(startSetting)
+		duration := time.Since(start.(time.Time))
+
+		// updates without changes don't run a statement
+		if scope.SQL == "" {
+			return
+		}
+		statement := NormalizeStatement(scope.SQL)
+		table := scope.TableName()
+		if table != "" {
+			span.SetName(operation + " " + table)
+		} else {
+			span.SetName(operation)
+		}
+		span.SetAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+			semconv.DBQueryText(statement),
+			attribute.Int64("db.rows_affected", scope.DB().RowsAffected),
+		)
+		if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		queryDuration.WithLabelValues(operation, table, statement).Observe(duration.Seconds())
+
+		if slowQueryThreshold > 0 && duration >= slowQueryThreshold {
+			logger.FromContext(scopeContext(scope)).With("table", table, "duration", duration.String()).
+				Warnf("slow query: %s", statement)
+		}
+	}
+}
+
+var (
+	spaces       = regexp.MustCompile(`\s+`)
+	stringValues = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberValues = regexp.MustCompile(`\b\d+\b`)
+	placeholders = regexp.MustCompile(`\$\d+`)
+	valueLists   = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+)
+
+// Statement without its values, so statements differing only in values (LIMIT 10,
+// IN lists of any length) are the same metric label and never leak data to traces
+func NormalizeStatement(sql string) string {
+	sql = stringValues.ReplaceAllString(sql, "?")
+	sql = placeholders.ReplaceAllString(sql, "?")
+	sql = numberValues.ReplaceAllString(sql, "?")
+	sql = valueLists.ReplaceAllString(sql, "(?)")
+	return strings.TrimSpace(spaces.ReplaceAllString(sql, " "))
+}
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+	cases := []struct {
+		sql, want string
+	}{
+		{
+			`SELECT * FROM "article_models"  WHERE "article_models"."deleted_at" IS NULL AND (("article_models"."slug" = $1)) ORDER BY "article_models"."id" ASC LIMIT 1`,
+			`SELECT * FROM "article_models" WHERE "article_models"."deleted_at" IS NULL AND (("article_models"."slug" = ?)) ORDER BY "article_models"."id" ASC LIMIT ?`,
+		},
+		{
+			`SELECT * FROM "tag_models" WHERE (id in ($1,$2,$3))`,
+			`SELECT * FROM "tag_models" WHERE (id in (?))`,
+		},
+		{
+			"SELECT * FROM \"user_models\"\n\tWHERE (email = 'jake@jake.jake' AND role = 'it''s')  LIMIT 10 OFFSET 20",
+			`SELECT * FROM "user_models" WHERE (email = ? AND role = ?) LIMIT ? OFFSET ?`,
+		},
+		{
+			`INSERT INTO "comment_models2" ("body") VALUES ($1) RETURNING "comment_models2"."id"`,
+			`INSERT INTO "comment_models2" ("body") VALUES (?) RETURNING "comment_models2"."id"`,
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, NormalizeStatement(c.sql))
+	}
+}
@@ -0,0 +1,325 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+)
+
+const migrationsTable = "schema_migrations"
+
+var (
+	ErrSchemaOutdated    = errors.New("database schema has pending migrations")
+	ErrSchemaAhead       = errors.New("database schema has migrations this build doesn't know")
+	ErrUnknownMigration  = errors.New("unknown migration version")
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// Every replica takes the same session level advisory lock before touching the schema
+var migrationsLockID = int64(crc32.ChecksumIEEE([]byte(migrationsTable)))
+
+// One versioned schema change, read from <version>_<name>.up.sql and <version>_<name>.down.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Applies the migrations of a source, each one runs in its own transaction
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     logger.Logger
+}
+
+func NewMigrator(db *DB, source fs.FS, logger logger.Logger) (*Migrator, error) {
+	migrations, err := LoadMigrations(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db.DB(), migrations: migrations, logger: logger}, nil
+}
+
+// Migrations of source ordered by version, every version needs an up and a down file
+func LoadMigrations(source fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		if version == 0 {
+			return nil, fmt.Errorf("migration %s: version 0 is reserved for the empty schema", entry.Name())
+		}
+		body, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs non empty up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Write empty up and down files for the version after the last one in dir
+func CreateMigration(dir, name string) ([]string, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("migration name %q may only contain a-z, 0-9 and _", name)
+	}
+	existing, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	version := int64(1)
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	var files []string
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %04d_%s %s\n", version, name, direction)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Version of the last migration known to this build
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Apply every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Roll back the last steps applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			if err := m.run(ctx, conn, m.migrations[i], false); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Apply the pending migrations up to version and roll back the ones after it, 0 rolls back everything
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				if err := m.run(ctx, conn, migration, false); err != nil {
+					return err
+				}
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := m.run(ctx, conn, migration, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Every known migration and when it was applied, followed by applied versions this build doesn't know
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		for version, appliedAt := range applied {
+			if !m.known(version) {
+				appliedAt := appliedAt
+				statuses = append(statuses, MigrationStatus{Migration: Migration{Version: version}, AppliedAt: &appliedAt})
+			}
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Refuse a schema that isn't exactly the one this build was written for
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.Name == "" {
+			return fmt.Errorf("%w: version %d is applied, this build knows up to %d", ErrSchemaAhead, status.Version, m.Latest())
+		}
+		if status.AppliedAt == nil {
+			return fmt.Errorf("%w: %d_%s isn't applied, run the migrate up command", ErrSchemaOutdated, status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Run fn on a single connection holding the migrations lock, creating the bookkeeping table first
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockID); err != nil {
+		return fmt.Errorf("migrations lock: %w", err)
+	}
+	defer func() {
+		// the lock is released with the session anyway, don't let a cancelled ctx keep it
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockID); err != nil {
+			m.logger.Errorf("Migrator.unlock: %v", err)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationsTable+` (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp with time zone NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	direction, script := "up", migration.Up
+	if !up {
+		direction, script = "down", migration.Down
+	}
+	start := time.Now()
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+migrationsTable+" (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+migrationsTable+" WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	m.logger.Infof("Migrated %s %d_%s in %s", direction, migration.Version, migration.Name, time.Since(start))
+	return nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+type txKey struct{}
+
+type memoryTxKey struct{}
+
+// Runs usecase operations atomically. Repositories get the transaction from the ctx
+// passed to fn through WithContext, so every call made with it is part of the transaction.
+type TxManager interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txManager struct {
+	db *DB
+}
+
+func NewTxManager(db *DB) TxManager {
+	return &txManager{db: db}
+}
+
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTransaction(ctx, m.db, fn)
+}
+
+// Commit when fn returns nil, roll back when it returns an error or panics.
+// Within a transaction already, fn joins it and the outermost call commits.
+func RunInTransaction(ctx context.Context, db *DB, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*DB); ok {
+		return fn(ctx)
+	}
+	// gorm's Begin ignores the context, database/sql rolls a BeginTx back once ctx is done
+	tx := db.BeginTx(ctx, &sql.TxOptions{})
+	if tx.Error != nil {
+		return fmt.Errorf("begin transaction: %w", tx.Error)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit().Error
+	}()
+	return fn(context.WithValue(ctx, txKey{}, tx))
+}
+
+// Transactions of repositories that keep their data in memory. There's nothing
+// to roll back, fn only runs one at a time with the other transactions.
+type memoryTxManager struct {
+	mu sync.Mutex
+}
+
+func NewMemoryTxManager() TxManager {
+	return &memoryTxManager{}
+}
+
+func (m *memoryTxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) != nil {
+		return fn(ctx)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(context.WithValue(ctx, memoryTxKey{}, m))
+}
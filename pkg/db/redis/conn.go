@@ -1,15 +1,19 @@
 package redis
 
 import (
+	"time"
+
 	"github.com/redis/go-redis/v9"
 )
 
 type Client = redis.Client
 
-func RedisInit(redisUrl string) *redis.Client {
+// Commands slower than slowCommand are logged as warnings, zero doesn't log any
+func RedisInit(redisUrl string, slowCommand time.Duration) *redis.Client {
 	redis := redis.NewClient(&redis.Options{
 		Network: "tcp",
 		Addr:    redisUrl,
 	})
+	redis.AddHook(instrumentationHook{slowCommand: slowCommand})
 	return redis
 }
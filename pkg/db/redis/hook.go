@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var commandDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "How long redis commands took, partitioned by command. Pipelines are one observation.",
+		Buckets:   []float64{.0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	},
+	[]string{"command"},
+)
+
+func init() {
+	prometheus.MustRegister(commandDuration)
+}
+
+// Child span of the request and duration observation for every command, commands
+// slower than slowCommand are logged as warnings, zero doesn't log any.
+// Only command names are recorded, keys and values may carry user data.
+type instrumentationHook struct {
+	slowCommand time.Duration
+}
+
+func (h instrumentationHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h instrumentationHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.observe(ctx, strings.ToUpper(cmd.Name()), 1, func(ctx context.Context) error {
+			return next(ctx, cmd)
+		})
+	}
+}
+
+func (h instrumentationHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, 0, len(cmds))
+		for _, cmd := range cmds {
+			names = append(names, strings.ToUpper(cmd.Name()))
+		}
+		return h.observe(ctx, "PIPELINE "+strings.Join(names, " "), len(cmds), func(ctx context.Context) error {
+			return next(ctx, cmds)
+		})
+	}
+}
+
+func (h instrumentationHook) observe(ctx context.Context, command string, size int, run func(ctx context.Context) error) error {
+	ctx, span := tracing.Start(ctx, command, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemRedis,
+		semconv.DBOperationName(command),
+		attribute.Int("db.redis.commands", size),
+	))
+	defer span.End()
+
+	start := time.Now()
+	err := run(ctx)
+	duration := time.Since(start)
+	commandDuration.WithLabelValues(command).Observe(duration.Seconds())
+
+	// a missing key is an answer, not a failure
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if h.slowCommand > 0 && duration >= h.slowCommand {
+		logger.FromContext(ctx).With("duration", duration.String()).Warnf("slow redis command: %s", command)
+	}
+	return err
+}
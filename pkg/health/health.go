@@ -0,0 +1,182 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// Checker reports whether a dependency can be used, it should give up when ctx is done
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is a function used as a Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Checker of a TCP service like an exporter or a broker, up when a connection can be opened
+func DialChecker(address string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// Status of one component, Latency is in milliseconds
+type ComponentStatus struct {
+	Status   string  `json:"status"`
+	Latency  float64 `json:"latency"`
+	Optional bool    `json:"optional,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Report of a readiness check, the instance is ready when Status is up
+type Report struct {
+	Status     string                     `json:"status"`
+	CheckedAt  time.Time                  `json:"checkedAt"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type check struct {
+	name     string
+	checker  Checker
+	optional bool
+}
+
+// Registry of the dependencies an instance needs to serve requests. Checks run
+// concurrently, each within timeout, and the report is reused for cacheTTL so
+// frequent probes of several load balancers don't load the dependencies.
+type Registry struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	checks []check
+	report *Report
+}
+
+// Health registry constructor
+func NewRegistry(timeout, cacheTTL time.Duration) *Registry {
+	return &Registry{timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Register a required dependency, the instance isn't ready while it's down
+func (r *Registry) Register(name string, checker Checker) {
+	r.register(check{name: name, checker: checker})
+}
+
+// Register a dependency the instance works without, like a tracing exporter.
+// It's reported but doesn't make the instance not ready.
+func (r *Registry) RegisterOptional(name string, checker Checker) {
+	r.register(check{name: name, checker: checker, optional: true})
+}
+
+func (r *Registry) register(c check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, c)
+	r.report = nil
+}
+
+// Mark the instance not ready for good, so load balancers stop sending requests before shutdown
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Check the dependencies, or return the report of a check younger than cacheTTL
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.report == nil || time.Since(r.report.CheckedAt) >= r.cacheTTL {
+		// a probe giving up mustn't leave a report of failures behind
+		r.report = r.run(context.WithoutCancel(ctx))
+	}
+	report := *r.report
+	if r.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context) *Report {
+	report := &Report{Status: StatusUp, CheckedAt: time.Now(), Components: make(map[string]ComponentStatus, len(r.checks))}
+	statuses := make([]ComponentStatus, len(r.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			statuses[i] = r.runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, c := range r.checks {
+		report.Components[c.name] = statuses[i]
+		if statuses[i].Status == StatusDown && !c.optional {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, c check) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.checker.Check(ctx)
+	status := ComponentStatus{
+		Status:   StatusUp,
+		Latency:  float64(time.Since(start).Microseconds()) / 1000,
+		Optional: c.optional,
+	}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Liveness probe, the process serves requests. Dependencies aren't checked so
+// an outage of postgres doesn't get every instance restarted.
+func (r *Registry) LivezHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": StatusUp})
+	}
+}
+
+// Readiness probe, 503 while a required dependency is down or the instance is draining
+func (r *Registry) ReadyzHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := r.Check(c.Request.Context())
+		status := http.StatusOK
+		if report.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(status, report)
+	}
+}
@@ -1,8 +1,10 @@
 package httpErrors
 
 import (
+	"errors"
 	"fmt"
 
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -10,21 +12,25 @@ type CommonError struct {
 	Errors map[string]interface{} `json:"errors"`
 }
 
-// To handle the error returned by c.Bind in gin framework
+// To handle the error returned by c.Bind in gin framework, errors that aren't
+// validation errors (like malformed json) are reported under the "body" key.
+// Messages are translated with trans, see GetTranslator
 // https://github.com/go-playground/validator/blob/v9/_examples/translations/main.go
-func NewValidatorError(err error) CommonError {
+func NewValidatorError(err error, trans ut.Translator) CommonError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return NewError("body", err)
+	}
 	res := CommonError{}
 	res.Errors = make(map[string]interface{})
-	errs := err.(validator.ValidationErrors)
 	for _, v := range errs {
-		// can translate each error one at a time.
-		//fmt.Println("gg",v.NameNamespace)
-		if v.Param() != "" {
+		if trans != nil {
+			res.Errors[v.Field()] = v.Translate(trans)
+		} else if v.Param() != "" {
 			res.Errors[v.Field()] = fmt.Sprintf("{%v: %v}", v.Tag(), v.Param())
 		} else {
 			res.Errors[v.Field()] = fmt.Sprintf("{key: %v}", v.Tag())
 		}
-
 	}
 	return res
 }
@@ -0,0 +1,40 @@
+package httpErrors
+
+import (
+	"net/http"
+	"strings"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// RFC 7807 problem details, Errors carries the same fields as the RealWorld errors object
+type Problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Errors   map[string]interface{} `json:"errors,omitempty"`
+}
+
+func NewProblem(status int, detail, instance string, errs CommonError) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+		Errors:   errs.Errors,
+	}
+}
+
+// Whether the client asked for problem details in the Accept header
+func AcceptsProblem(accept string) bool {
+	for _, mediaType := range strings.Split(accept, ",") {
+		mediaType, _, _ = strings.Cut(mediaType, ";")
+		if strings.TrimSpace(mediaType) == ProblemContentType {
+			return true
+		}
+	}
+	return false
+}
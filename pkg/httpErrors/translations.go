@@ -0,0 +1,112 @@
+package httpErrors
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+// Message catalogs of the validator, the first one is the fallback
+var catalogs = []struct {
+	translator locales.Translator
+	register   func(v *validator.Validate, trans ut.Translator) error
+}{
+	{en.New(), enTranslations.RegisterDefaultTranslations},
+	{zh.New(), zhTranslations.RegisterDefaultTranslations},
+}
+
+var (
+	uni      *ut.UniversalTranslator
+	initOnce sync.Once
+	initErr  error
+)
+
+// Register the message catalogs on the validator gin binds with, and name
+// fields after their json key so errors read "tagList" instead of "Tags"
+func ValidatorTranslationsInit() error {
+	initOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+
+		fallback := catalogs[0].translator
+		uni = ut.New(fallback, fallback)
+		for _, catalog := range catalogs {
+			if catalog.translator != fallback {
+				if initErr = uni.AddTranslator(catalog.translator, true); initErr != nil {
+					return
+				}
+			}
+			trans, _ := uni.GetTranslator(catalog.translator.Locale())
+			if initErr = catalog.register(v, trans); initErr != nil {
+				return
+			}
+		}
+	})
+	return initErr
+}
+
+// Translator for the best language of an Accept-Language header, english when none is supported
+func GetTranslator(acceptLanguage string) ut.Translator {
+	if uni == nil {
+		return nil
+	}
+	trans, _ := uni.FindTranslator(parseAcceptLanguage(acceptLanguage)...)
+	return trans
+}
+
+// Locales of an Accept-Language header ordered by quality, "zh-TW" is tried as "zh_TW" then "zh"
+func parseAcceptLanguage(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			quality = parsed
+		}
+		languages = append(languages, language{tag, quality})
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	locales := make([]string, 0, len(languages)*2)
+	for _, l := range languages {
+		locale := strings.ReplaceAll(l.tag, "-", "_")
+		locales = append(locales, locale)
+		if base, _, ok := strings.Cut(locale, "_"); ok {
+			locales = append(locales, base)
+		}
+	}
+	return locales
+}
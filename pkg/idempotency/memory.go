@@ -0,0 +1,49 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Responses kept by a single process, for running without redis
+type memoryStore struct {
+	mu        sync.Mutex
+	expire    time.Duration
+	responses map[string]memoryResponse
+}
+
+type memoryResponse struct {
+	response  Response
+	expiresAt time.Time
+}
+
+func NewMemoryStore(expire time.Duration) Store {
+	return &memoryStore{expire: expire, responses: make(map[string]memoryResponse)}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.responses[key]
+	// like redis, a zero expire keeps the response
+	if !ok || (!stored.expiresAt.IsZero() && !stored.expiresAt.After(time.Now())) {
+		delete(s.responses, key)
+		return nil, ErrNotFound
+	}
+	response := stored.response
+	return &response, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, key string, response *Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := memoryResponse{response: *response}
+	if s.expire > 0 {
+		stored.expiresAt = time.Now().Add(s.expire)
+	}
+	s.responses[key] = stored
+	return nil
+}
@@ -0,0 +1,73 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNotFound = errors.New("idempotency: no stored response")
+
+// Response captured for an Idempotency-Key, Fingerprint identifies the request it answered
+type Response struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+}
+
+type Store interface {
+	Get(ctx context.Context, key string) (*Response, error)
+	Save(ctx context.Context, key string, response *Response) error
+}
+
+type store struct {
+	cfg   *config.Config
+	Redis *redis.Client
+}
+
+func IdempotencyStoreInit(redisClient *redis.Client, cfg *config.Config) Store {
+	return &store{cfg: cfg, Redis: redisClient}
+}
+
+// Hash of the method, path and body, a key may only be reused for the same request
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *store) Get(ctx context.Context, key string) (*Response, error) {
+	data, err := s.Redis.Get(ctx, s.buildKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	response := &Response{}
+	if err = json.Unmarshal(data, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *store) Save(ctx context.Context, key string, response *Response) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.Redis.Set(ctx, s.buildKey(key), data, time.Duration(s.cfg.Idempotency.Expire)*time.Second).Err()
+}
+
+func (s *store) buildKey(key string) string {
+	return fmt.Sprintf("%s:%s:%s", s.cfg.Server.AppName, s.cfg.Idempotency.Prefix, key)
+}
@@ -0,0 +1,152 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bsm/redislock"
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/apperrors"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+)
+
+const (
+	defaultTTL  = 30 * time.Second
+	defaultWait = 10 * time.Second
+)
+
+var (
+	ErrNotObtained = redislock.ErrNotObtained
+	ErrLockNotHeld = redislock.ErrLockNotHeld
+)
+
+// Errors of ObtainLock for the client, somebody else holding the lock is a conflict
+// and a lock service that can't be reached makes the api unavailable
+var (
+	ErrLocked      = apperrors.Conflict("lock", errors.New("The resource is being changed by another request, try again"))
+	ErrUnavailable = apperrors.Unavailable("lock", errors.New("Try again later"))
+)
+
+// Held lock, its lease is refreshed in the background until Release
+type Lock interface {
+	// Fencing token, greater than the tokens of all locks obtained before.
+	// Storages given the token can reject writes of holders which lost the lock.
+	Token() int64
+	Release(ctx context.Context) error
+}
+
+type Locker interface {
+	// Obtain the lock of key, ErrNotObtained when somebody else still holds it after waiting
+	ObtainLock(ctx context.Context, key string, opts ...Option) (Lock, error)
+}
+
+type options struct {
+	ttl  time.Duration
+	wait time.Duration
+}
+
+type Option func(*options)
+
+// Lease of the lock, a holder that stops refreshing it loses the lock after ttl
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// How long to retry while somebody else holds the lock, zero doesn't wait
+func WithWait(wait time.Duration) Option {
+	return func(o *options) {
+		o.wait = wait
+	}
+}
+
+func newOptions(cfg *config.Config, opts []Option) options {
+	o := options{ttl: defaultTTL, wait: defaultWait}
+	if cfg.Locker.TTL > 0 {
+		o.ttl = time.Duration(cfg.Locker.TTL) * time.Second
+	}
+	if cfg.Locker.Wait > 0 {
+		o.wait = time.Duration(cfg.Locker.Wait) * time.Second
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Error of a failed ObtainLock to render for the client
+func AppError(err error) error {
+	if errors.Is(err, ErrNotObtained) {
+		return ErrLocked
+	}
+	return ErrUnavailable
+}
+
+// Metric label of key, the part before the first colon like "article" of "article:slug-x"
+func keyName(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// Lock of a backend, heldLock keeps it alive
+type lease interface {
+	refresh(ctx context.Context, ttl time.Duration) error
+	release(ctx context.Context) error
+}
+
+type heldLock struct {
+	lease      lease
+	name       string
+	token      int64
+	obtainedAt time.Time
+	stop       chan struct{}
+	done       chan struct{}
+	once       sync.Once
+	err        error
+}
+
+// Refresh the lease every half ttl until the lock is released or lost
+func hold(ctx context.Context, l lease, name string, token int64, ttl time.Duration) *heldLock {
+	held := &heldLock{lease: l, name: name, token: token, obtainedAt: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	log := logger.FromContext(ctx)
+	go func() {
+		defer close(held.done)
+		ticker := time.NewTicker(ttl / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-held.stop:
+				return
+			case <-ticker.C:
+			}
+			refreshCtx, cancel := context.WithTimeout(context.Background(), ttl/2)
+			err := l.refresh(refreshCtx, ttl)
+			cancel()
+			if err != nil {
+				log.Warnf("Could not refresh lock %s: %v", name, err)
+				return
+			}
+		}
+	}()
+	return held
+}
+
+func (h *heldLock) Token() int64 {
+	return h.token
+}
+
+func (h *heldLock) Release(ctx context.Context) error {
+	h.once.Do(func() {
+		close(h.stop)
+		<-h.done
+		lockHoldSeconds.WithLabelValues(h.name).Observe(time.Since(h.obtainedAt).Seconds())
+		h.err = h.lease.release(ctx)
+	})
+	return h.err
+}
@@ -0,0 +1,111 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+)
+
+// Locks of a single process, for running without redis
+type memoryLocker struct {
+	cfg   *config.Config
+	mu    sync.Mutex
+	locks map[string]memoryLockEntry
+	token int64
+}
+
+type memoryLockEntry struct {
+	token     int64
+	expiresAt time.Time
+}
+
+type memoryLease struct {
+	locker *memoryLocker
+	key    string
+	token  int64
+}
+
+func NewMemoryLocker(cfg *config.Config) Locker {
+	return &memoryLocker{cfg: cfg, locks: make(map[string]memoryLockEntry)}
+}
+
+func (l *memoryLocker) ObtainLock(ctx context.Context, key string, opts ...Option) (Lock, error) {
+	o := newOptions(l.cfg, opts)
+	start := time.Now()
+	lease, err := l.wait(ctx, key, o)
+	lockWaitSeconds.WithLabelValues(keyName(key), waitResult(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		logger.FromContext(ctx).Warnf("Could not obtain lock %s: %v", key, err)
+		return nil, err
+	}
+	return hold(ctx, lease, keyName(key), lease.token, o.ttl), nil
+}
+
+func (l *memoryLocker) wait(ctx context.Context, key string, o options) (*memoryLease, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, o.wait)
+	defer cancel()
+
+	for {
+		if lease := l.obtain(key, o.ttl); lease != nil {
+			return lease, nil
+		}
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, ErrNotObtained
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func (l *memoryLocker) obtain(key string, ttl time.Duration) *memoryLease {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := l.locks[key]; ok && entry.expiresAt.After(now) {
+		return nil
+	}
+	l.token++
+	l.locks[key] = memoryLockEntry{token: l.token, expiresAt: now.Add(ttl)}
+	return &memoryLease{locker: l, key: key, token: l.token}
+}
+
+// An expired lock may already belong to somebody else, only the holder's token keeps it
+func (m *memoryLease) held() (memoryLockEntry, error) {
+	entry, ok := m.locker.locks[m.key]
+	if !ok || entry.token != m.token || !entry.expiresAt.After(time.Now()) {
+		return entry, ErrLockNotHeld
+	}
+	return entry, nil
+}
+
+func (m *memoryLease) refresh(ctx context.Context, ttl time.Duration) error {
+	m.locker.mu.Lock()
+	defer m.locker.mu.Unlock()
+
+	// like redislock, a lease which ran out can't be refreshed
+	entry, err := m.held()
+	if err != nil {
+		return ErrNotObtained
+	}
+	entry.expiresAt = time.Now().Add(ttl)
+	m.locker.locks[m.key] = entry
+	return nil
+}
+
+func (m *memoryLease) release(ctx context.Context) error {
+	m.locker.mu.Lock()
+	defer m.locker.mu.Unlock()
+
+	if _, err := m.held(); err != nil {
+		return err
+	}
+	delete(m.locker.locks, m.key)
+	return nil
+}
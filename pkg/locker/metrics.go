@@ -0,0 +1,41 @@
+package locker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lockWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "locker",
+			Name:      "wait_seconds",
+			Help:      "How long ObtainLock took, partitioned by key name and result.",
+			Buckets:   []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"name", "result"},
+	)
+	lockHoldSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "locker",
+			Name:      "hold_seconds",
+			Help:      "How long locks were held until released, partitioned by key name.",
+			Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"name"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(lockWaitSeconds, lockHoldSeconds)
+}
+
+// Result label of an ObtainLock error
+func waitResult(err error) string {
+	switch {
+	case err == nil:
+		return "obtained"
+	case err == ErrNotObtained:
+		return "busy"
+	}
+	return "error"
+}
@@ -2,19 +2,16 @@ package locker
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/bsm/redislock"
 	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
 	"github.com/redis/go-redis/v9"
 )
 
-type Locker interface {
-	ObtainLock(ctx context.Context, key string) *redislock.Lock
-}
-
 type locker struct {
 	cfg   *config.Config
 	Redis *redis.Client
@@ -28,23 +25,53 @@ func LockerInit(redisClient *redis.Client, cfg *config.Config) Locker {
 	return &locker{cfg: cfg, Redis: redisClient, Lock: lockClient}
 }
 
-func (l *locker) ObtainLock(ctx context.Context, key string) *redislock.Lock {
-	lockCtx, cancel := context.WithDeadline(ctx, time.Now().Add(2*time.Minute))
+func (l *locker) ObtainLock(ctx context.Context, key string, opts ...Option) (Lock, error) {
+	o := newOptions(l.cfg, opts)
+	start := time.Now()
+	lock, err := l.obtain(ctx, key, o)
+	lockWaitSeconds.WithLabelValues(keyName(key), waitResult(err)).Observe(time.Since(start).Seconds())
+	if err == ErrNotObtained {
+		logger.FromContext(ctx).Warnf("Could not obtain lock %s", key)
+		return nil, err
+	} else if err != nil {
+		logger.FromContext(ctx).Errorf("locker.ObtainLock %s: %v", key, err)
+		return nil, err
+	}
+
+	// one counter for all keys, tokens of the same key are increasing as well
+	token, err := l.Redis.Incr(ctx, fmt.Sprintf("%s:locker:fencing-token", l.cfg.Server.AppName)).Result()
+	if err != nil {
+		logger.FromContext(ctx).Errorf("locker.ObtainLock %s: %v", key, err)
+		lock.Release(ctx)
+		return nil, err
+	}
+	return hold(ctx, redisLease{lock}, keyName(key), token, o.ttl), nil
+}
+
+func (l *locker) obtain(ctx context.Context, key string, o options) (*redislock.Lock, error) {
+	if o.wait <= 0 {
+		return l.Lock.Obtain(ctx, fmt.Sprintf("%s:%s", l.cfg.Server.AppName, key), o.ttl, nil)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, o.wait)
 	defer cancel()
 
-	// Obtain lock with retry + custom deadline
-	lock, err := l.Lock.Obtain(lockCtx,
-		fmt.Sprintf("%s:%s", l.cfg.Server.AppName, key),
-		30*time.Second,
+	lock, err := l.Lock.Obtain(waitCtx, fmt.Sprintf("%s:%s", l.cfg.Server.AppName, key), o.ttl,
 		&redislock.Options{RetryStrategy: backoff})
-
-	if err == redislock.ErrNotObtained {
-		fmt.Println("Could not obtain lock!")
-	} else if err != nil {
-		log.Fatalln(err)
+	// redislock gives up with the error of the context, waiting for too long isn't an outage
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, ErrNotObtained
 	}
+	return lock, err
+}
+
+type redisLease struct {
+	lock *redislock.Lock
+}
+
+func (r redisLease) refresh(ctx context.Context, ttl time.Duration) error {
+	return r.lock.Refresh(ctx, ttl, nil)
+}
 
-	// Don't forget to defer Release.
-	// fmt.Println("I have a lock!")
-	return lock
+func (r redisLease) release(ctx context.Context) error {
+	return r.lock.Release(ctx)
 }
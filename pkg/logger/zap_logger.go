@@ -0,0 +1,187 @@
+package logger
+
+import (
+	"context"
+	"os"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger methods interface
+type Logger interface {
+	InitLogger()
+	With(keysAndValues ...interface{}) Logger
+	Debug(args ...interface{})
+	Debugf(template string, args ...interface{})
+	Info(args ...interface{})
+	Infof(template string, args ...interface{})
+	Warn(args ...interface{})
+	Warnf(template string, args ...interface{})
+	Error(args ...interface{})
+	Errorf(template string, args ...interface{})
+	DPanic(args ...interface{})
+	DPanicf(template string, args ...interface{})
+	Fatal(args ...interface{})
+	Fatalf(template string, args ...interface{})
+}
+
+// Logger
+type apiLogger struct {
+	cfg         *config.Config
+	sugarLogger *zap.SugaredLogger
+}
+
+// Used by FromContext when no request logger was set, replaced by InitLogger
+var defaultLogger Logger = &apiLogger{sugarLogger: zap.NewNop().Sugar()}
+
+// Level of the loggers InitLogger creates, it can be changed while the app runs
+var level = zap.NewAtomicLevel()
+
+type loggerCtxKey struct{}
+
+// App Logger constructor
+func NewApiLogger(cfg *config.Config) *apiLogger {
+	return &apiLogger{cfg: cfg}
+}
+
+// For mapping config logger to app logger levels
+var loggerLevelMap = map[string]zapcore.Level{
+	"debug":  zapcore.DebugLevel,
+	"info":   zapcore.InfoLevel,
+	"warn":   zapcore.WarnLevel,
+	"error":  zapcore.ErrorLevel,
+	"dpanic": zapcore.DPanicLevel,
+	"panic":  zapcore.PanicLevel,
+	"fatal":  zapcore.FatalLevel,
+}
+
+func (l *apiLogger) getLoggerLevel(cfg *config.Config) zapcore.Level {
+	level, exist := loggerLevelMap[cfg.Logger.Level]
+	if !exist {
+		return zapcore.DebugLevel
+	}
+
+	return level
+}
+
+// Init logger, it also becomes the fallback of FromContext
+func (l *apiLogger) InitLogger() {
+	logLevel := l.getLoggerLevel(l.cfg)
+
+	logWriter := zapcore.AddSync(os.Stderr)
+
+	var encoderCfg zapcore.EncoderConfig
+	if l.cfg.Logger.Development {
+		encoderCfg = zap.NewDevelopmentEncoderConfig()
+	} else {
+		encoderCfg = zap.NewProductionEncoderConfig()
+	}
+
+	encoderCfg.LevelKey = "LEVEL"
+	encoderCfg.CallerKey = "CALLER"
+	encoderCfg.TimeKey = "TIME"
+	encoderCfg.NameKey = "NAME"
+	encoderCfg.MessageKey = "MESSAGE"
+	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	if l.cfg.Logger.Encoding == "console" {
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderCfg)
+	}
+
+	var options []zap.Option
+	if !l.cfg.Logger.DisableCaller {
+		options = append(options, zap.AddCaller(), zap.AddCallerSkip(1))
+	}
+	if !l.cfg.Logger.DisableStacktrace {
+		options = append(options, zap.AddStacktrace(zapcore.ErrorLevel))
+	}
+	if l.cfg.Logger.Development {
+		options = append(options, zap.Development())
+	}
+
+	level.SetLevel(logLevel)
+	core := zapcore.NewCore(encoder, logWriter, level)
+	logger := zap.New(core, options...)
+
+	l.sugarLogger = logger.Sugar()
+	defaultLogger = l
+}
+
+// Level of the app's loggers, as an http.Handler GET reports it and PUT
+// with a body like {"level":"debug"} changes it
+func Level() zap.AtomicLevel {
+	return level
+}
+
+// Child logger adding the given key value pairs to every entry
+func (l *apiLogger) With(keysAndValues ...interface{}) Logger {
+	return &apiLogger{cfg: l.cfg, sugarLogger: l.sugarLogger.With(keysAndValues...)}
+}
+
+// Logger methods
+
+func (l *apiLogger) Debug(args ...interface{}) {
+	l.sugarLogger.Debug(args...)
+}
+
+func (l *apiLogger) Debugf(template string, args ...interface{}) {
+	l.sugarLogger.Debugf(template, args...)
+}
+
+func (l *apiLogger) Info(args ...interface{}) {
+	l.sugarLogger.Info(args...)
+}
+
+func (l *apiLogger) Infof(template string, args ...interface{}) {
+	l.sugarLogger.Infof(template, args...)
+}
+
+func (l *apiLogger) Warn(args ...interface{}) {
+	l.sugarLogger.Warn(args...)
+}
+
+func (l *apiLogger) Warnf(template string, args ...interface{}) {
+	l.sugarLogger.Warnf(template, args...)
+}
+
+func (l *apiLogger) Error(args ...interface{}) {
+	l.sugarLogger.Error(args...)
+}
+
+func (l *apiLogger) Errorf(template string, args ...interface{}) {
+	l.sugarLogger.Errorf(template, args...)
+}
+
+func (l *apiLogger) DPanic(args ...interface{}) {
+	l.sugarLogger.DPanic(args...)
+}
+
+func (l *apiLogger) DPanicf(template string, args ...interface{}) {
+	l.sugarLogger.DPanicf(template, args...)
+}
+
+func (l *apiLogger) Fatal(args ...interface{}) {
+	l.sugarLogger.Fatal(args...)
+}
+
+func (l *apiLogger) Fatalf(template string, args ...interface{}) {
+	l.sugarLogger.Fatalf(template, args...)
+}
+
+// Context with the request scoped logger
+func WithContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// Request scoped logger of ctx, the default logger when there is none
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(Logger); ok {
+		return l
+	}
+	return defaultLogger
+}
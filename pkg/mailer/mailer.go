@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+)
+
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// Sends plain text mails through the configured SMTP server
+type smtpMailer struct {
+	cfg *config.Config
+}
+
+// Logs the recipient and subject of mails, used when no SMTP host is configured.
+// The body isn't logged, it carries secrets like password reset tokens.
+type logMailer struct{}
+
+func MailerInit(cfg *config.Config) Mailer {
+	if cfg.Mailer.Host == "" {
+		return &logMailer{}
+	}
+	return &smtpMailer{cfg: cfg}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, to, subject, body string) error {
+	addr := fmt.Sprintf("%s:%d", m.cfg.Mailer.Host, m.cfg.Mailer.Port)
+
+	var auth smtp.Auth
+	if m.cfg.Mailer.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Mailer.Username, m.cfg.Mailer.Password, m.cfg.Mailer.Host)
+	}
+	return smtp.SendMail(addr, auth, m.cfg.Mailer.From, []string{to}, buildMessage(m.cfg.Mailer.From, to, subject, body))
+}
+
+func (m *logMailer) Send(ctx context.Context, to, subject, body string) error {
+	logger.FromContext(ctx).Infof("Mailer To: %s, Subject: %s", to, subject)
+	return nil
+}
+
+func buildMessage(from, to, subject, body string) []byte {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	sb.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	sb.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(body)
+	return []byte(sb.String())
+}
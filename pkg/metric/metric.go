@@ -2,6 +2,7 @@ package metric
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -139,11 +140,9 @@ func NewPrometheus(subsystem string, customMetricsList ...[]*Metric) *Prometheus
 	}
 
 	p := &Prometheus{
-		MetricsList: metricsList,
-		MetricsPath: defaultMetricPath,
-		ReqCntURLLabelMappingFn: func(c *gin.Context) string {
-			return c.Request.URL.Path // i.e. by default do nothing, i.e. return URL as is
-		},
+		MetricsList:             metricsList,
+		MetricsPath:             defaultMetricPath,
+		ReqCntURLLabelMappingFn: RouteTemplate,
 	}
 
 	p.registerMetrics(subsystem)
@@ -213,8 +212,11 @@ func (p *Prometheus) runServer() {
 }
 
 func (p *Prometheus) getMetrics() []byte {
-	response, _ := http.Get(p.Ppg.MetricsURL)
-
+	response, err := http.Get(p.Ppg.MetricsURL)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
 	defer response.Body.Close()
 	body, _ := ioutil.ReadAll(response.Body)
 
@@ -241,7 +243,9 @@ func (p *Prometheus) startPushTicker() {
 	ticker := time.NewTicker(time.Second * p.Ppg.PushIntervalSeconds)
 	go func() {
 		for range ticker.C {
-			p.sendMetricsToPushGateway(p.getMetrics())
+			if metrics := p.getMetrics(); metrics != nil {
+				p.sendMetricsToPushGateway(metrics)
+			}
 		}
 	}()
 }
@@ -327,7 +331,12 @@ func (p *Prometheus) registerMetrics(subsystem string) {
 	for _, metricDef := range p.MetricsList {
 		metric := NewMetric(metricDef, subsystem)
 		if err := prometheus.Register(metric); err != nil {
-			log.Fatalln(err)
+			// a second server of the process, like in tests, shares the collectors
+			var registered prometheus.AlreadyRegisteredError
+			if !errors.As(err, &registered) {
+				log.Fatalln(err)
+			}
+			metric = registered.ExistingCollector
 		}
 		switch metricDef {
 		case reqCnt:
@@ -393,6 +402,15 @@ func (p *Prometheus) ObserveResponseTime(status int, method, path string, observ
 	p.reqDur.WithLabelValues(strconv.Itoa(status), method, path).Observe(observeTime)
 }
 
+// Route template of the request, like "/api/articles/:slug", so slugs and usernames
+// don't each make a series. Requests matching no route share the "unmatched" label.
+func RouteTemplate(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
 func prometheusHandler() gin.HandlerFunc {
 	h := promhttp.Handler()
 	return func(c *gin.Context) {
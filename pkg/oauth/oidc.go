@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnknownProvider = errors.New("Unknown provider")
+	ErrInvalidIDToken  = errors.New("Invalid id token")
+)
+
+// Claims of a verified id token
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// OpenID Connect authorization code flow with PKCE
+type Client interface {
+	AuthCodeURL(ctx context.Context, provider, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, provider, code, nonce, verifier string) (*Identity, error)
+}
+
+type client struct {
+	cfg       *config.Config
+	mu        sync.Mutex
+	providers map[string]*provider
+}
+
+type provider struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func OAuthInit(cfg *config.Config) Client {
+	return &client{cfg: cfg, providers: make(map[string]*provider)}
+}
+
+// Build the url the user is redirected to, the challenge is derived from verifier
+func (c *client) AuthCodeURL(ctx context.Context, name, state, nonce, verifier string) (string, error) {
+	p, err := c.provider(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Trade the authorization code for tokens and verify the id token
+func (c *client) Exchange(ctx context.Context, name, code, nonce, verifier string) (*Identity, error) {
+	p, err := c.provider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrInvalidIDToken
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidIDToken
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Subject:           idToken.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// Providers are discovered on first use, so an unreachable issuer doesn't stop the server from starting
+func (c *client) provider(ctx context.Context, name string) (*provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, ok := c.providers[name]; ok {
+		return p, nil
+	}
+	providerCfg, ok := c.cfg.OAuth.Providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	oidcProvider, err := oidc.NewProvider(ctx, providerCfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	scopes := append([]string{oidc.ScopeOpenID}, providerCfg.Scopes...)
+	p := &provider{
+		oauth2: oauth2.Config{
+			ClientID:     providerCfg.ClientID,
+			ClientSecret: providerCfg.ClientSecret,
+			RedirectURL:  providerCfg.RedirectURL,
+			Endpoint:     oidcProvider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: oidcProvider.Verifier(&oidc.Config{ClientID: providerCfg.ClientID}),
+	}
+	c.providers[name] = p
+	return p, nil
+}
@@ -0,0 +1,178 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID = "realworld"
+	testCode     = "test-code"
+)
+
+// Minimal OpenID Connect provider: discovery, jwks and a token endpoint checking PKCE
+type testProvider struct {
+	*httptest.Server
+	key           *rsa.PrivateKey
+	codeChallenge string
+	nonce         string
+	claims        map[string]interface{}
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &testProvider{key: key, claims: map[string]interface{}{
+		"sub":            "subject-1",
+		"email":          "jake@jake.jake",
+		"email_verified": true,
+	}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &p.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != testCode || base64.RawURLEncoding.EncodeToString(sum[:]) != p.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     p.signIDToken(t),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *testProvider) signIDToken(t *testing.T) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"),
+	)
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{
+		"iss":   p.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": p.nonce,
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	jws, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := jws.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+// Follow the authorization url like a browser would, remembering what the provider received
+func (p *testProvider) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, p.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	p.codeChallenge = u.Query().Get("code_challenge")
+	p.nonce = u.Query().Get("nonce")
+}
+
+func newTestClient(issuer string) Client {
+	return OAuthInit(&config.Config{OAuth: config.OAuth{Providers: map[string]config.OAuthProvider{
+		"test": {
+			Issuer:      issuer,
+			ClientID:    testClientID,
+			RedirectURL: "http://localhost/api/users/oauth/test/callback",
+			Scopes:      []string{"email"},
+		},
+	}}})
+}
+
+func TestExchange(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.Close()
+	client := newTestClient(provider.URL)
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "test", "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	require.NoError(t, err)
+	provider.authorize(t, authURL)
+	assert.Equal(t, "nonce", provider.nonce)
+
+	identity, err := client.Exchange(ctx, "test", testCode, "nonce", "verifier-verifier-verifier-verifier-verifier")
+	require.NoError(t, err)
+	assert.Equal(t, "subject-1", identity.Subject)
+	assert.Equal(t, "jake@jake.jake", identity.Email)
+	assert.True(t, identity.EmailVerified)
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.Close()
+	client := newTestClient(provider.URL)
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "test", "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	require.NoError(t, err)
+	provider.authorize(t, authURL)
+
+	_, err = client.Exchange(ctx, "test", testCode, "nonce", "another-verifier-another-verifier-another")
+	assert.Error(t, err)
+}
+
+func TestExchangeWrongNonce(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.Close()
+	client := newTestClient(provider.URL)
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "test", "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	require.NoError(t, err)
+	provider.authorize(t, authURL)
+
+	_, err = client.Exchange(ctx, "test", testCode, "replayed-nonce", "verifier-verifier-verifier-verifier-verifier")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestUnknownProvider(t *testing.T) {
+	client := newTestClient("http://127.0.0.1:0")
+
+	_, err := client.AuthCodeURL(context.Background(), "missing", "state", "nonce", "verifier")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
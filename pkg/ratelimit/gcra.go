@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate requests per Period, Burst requests may be sent at once
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+type Result struct {
+	Limit      Limit
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // zero when allowed
+	ResetAfter time.Duration // until the bucket is full again
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+type limiter struct {
+	cfg   *config.Config
+	Redis *redis.Client
+}
+
+// Generic cell rate algorithm, the only state is the theoretical arrival time of the next request.
+// Time is taken from redis so every api instance shares the same clock.
+var gcra = redis.NewScript(`
+redis.replicate_commands()
+
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local emission_interval = period / rate
+local burst_offset = emission_interval * burst
+
+local now = redis.call("TIME")
+now = (now[1] - 1577836800) + (now[2] / 1000000)
+
+local tat = redis.call("GET", key)
+if not tat then
+  tat = now
+else
+  tat = math.max(tonumber(tat), now)
+end
+
+local new_tat = tat + emission_interval
+local diff = now - (new_tat - burst_offset)
+if diff < 0 then
+  return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call("SET", key, new_tat, "EX", math.ceil(reset_after))
+return {1, math.floor(diff / emission_interval), "0", tostring(reset_after)}
+`)
+
+func RateLimiterInit(redisClient *redis.Client, cfg *config.Config) Limiter {
+	return &limiter{cfg: cfg, Redis: redisClient}
+}
+
+func (l *limiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	values, err := gcra.Run(ctx, l.Redis,
+		[]string{fmt.Sprintf("%s:%s:%s", l.cfg.Server.AppName, l.cfg.RateLimit.Prefix, key)},
+		limit.Burst, limit.Rate, limit.Period.Seconds(),
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	retryAfter, err := strconv.ParseFloat(values[2].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+	resetAfter, err := strconv.ParseFloat(values[3].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Limit:      limit,
+		Allowed:    values[0].(int64) == 1,
+		Remaining:  int(values[1].(int64)),
+		RetryAfter: secondsToDuration(retryAfter),
+		ResetAfter: secondsToDuration(resetAfter),
+	}, nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
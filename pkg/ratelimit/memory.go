@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// The same generic cell rate algorithm as the redis limiter, for a single process
+type memoryLimiter struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+}
+
+func NewMemoryLimiter() Limiter {
+	return &memoryLimiter{tats: make(map[string]time.Time)}
+}
+
+func (l *memoryLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	emissionInterval := limit.Period / time.Duration(limit.Rate)
+	burstOffset := emissionInterval * time.Duration(limit.Burst)
+
+	tat := now
+	if stored, ok := l.tats[key]; ok && stored.After(now) {
+		tat = stored
+	}
+	newTat := tat.Add(emissionInterval)
+	diff := now.Sub(newTat.Add(-burstOffset))
+	if diff < 0 {
+		return &Result{Limit: limit, RetryAfter: -diff, ResetAfter: tat.Sub(now)}, nil
+	}
+
+	l.tats[key] = newTat
+	return &Result{
+		Limit:      limit,
+		Allowed:    true,
+		Remaining:  int(diff / emissionInterval),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
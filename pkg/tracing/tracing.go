@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gothinkster/golang-gin-realworld-example-app/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/gothinkster/golang-gin-realworld-example-app"
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterNone   = "none"
+)
+
+// Tracer of the app, spans go to the provider installed by TracerInit
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// W3C trace context and baggage, extracted from requests even without TracerInit
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// Start a span as a child of the span of ctx, End it when done
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// Install the global tracer provider and the W3C trace context propagator.
+// Spans are still created with the none exporter so logs and responses get trace ids.
+// shutdown flushes the spans left.
+func TracerInit(ctx context.Context, cfg *config.Config) (shutdown func(context.Context) error, err error) {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(cfg.Tracing.ServiceName),
+			semconv.ServiceVersion(cfg.Server.AppVersion),
+			semconv.DeploymentEnvironment(cfg.Server.Mode),
+		)),
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Tracing.Exporter {
+	case ExporterOTLP:
+		exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Tracing.Endpoint)}
+		if cfg.Tracing.Insecure {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, exporterOpts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterNone, "":
+	default:
+		err = fmt.Errorf("unknown tracing exporter %q, expected otlp, stdout or none", cfg.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(Propagator())
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Commit of the build, set with -ldflags "-X github.com/gothinkster/golang-gin-realworld-example-app/pkg/utils.Commit=..."
+// or read from the vcs info go build embeds
+var Commit string
+
+// Version, commit and toolchain of the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+func GetBuildInfo(version string) BuildInfo {
+	info := BuildInfo{Version: version, Commit: Commit, GoVersion: runtime.Version()}
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
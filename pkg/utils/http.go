@@ -2,19 +2,31 @@ package utils
 
 import (
 	"context"
+	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/opentracing/opentracing-go"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/logger"
+	"github.com/gothinkster/golang-gin-realworld-example-app/pkg/tracing"
 	requestid "github.com/sumit-tembe/gin-requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Get config path for local or docker
-func GetConfigPath(configPath string) string {
-	if configPath == "docker" {
-		return "./config/config-docker"
+// Path of the config of a profile, ./config/config-<profile>.yml without the extension.
+// An empty profile is read from APP_PROFILE, then the config variable of older
+// deployments, and is local when neither is set.
+func GetConfigPath(profile string) string {
+	if profile == "" {
+		profile = os.Getenv("APP_PROFILE")
 	}
-	return "./config/config-local"
+	if profile == "" {
+		profile = os.Getenv("config")
+	}
+	if profile == "" {
+		profile = "local"
+	}
+	return "./config/config-" + profile
 }
 
 // Get request id from echo context
@@ -36,21 +48,40 @@ func GetResourceFromPath(path string) *string {
 	return &resource
 }
 
+// Whether an If-None-Match header lists etag, compared weakly like the header calls for
+func ETagMatches(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
 // ReqIDCtxKey is a key used for the Request ID in context
 type ReqIDCtxKey struct{}
 
-// Get context  with request id
+// Get context  with request id, the request logger also gets the id of the signed in user
 func GetRequestCtx(c *gin.Context) context.Context {
 	ctx := context.WithValue(c.Request.Context(), ReqIDCtxKey{}, GetRequestID(c))
 	for key, value := range c.Keys {
 		ctx = context.WithValue(ctx, key, value)
 	}
+	if myUserID := c.GetUint("my_user_id"); myUserID != 0 {
+		ctx = logger.WithContext(ctx, logger.FromContext(ctx).With("userId", myUserID))
+	}
 	return ctx
 }
 
 func WithTelemetry(c *gin.Context, key string, mainFunc func(spanCtx context.Context)) {
-	span, ctx := opentracing.StartSpanFromContext(GetRequestCtx(c), key)
-	span.SetTag("requestId", GetRequestID(c))
-	defer span.Finish()
+	ctx, span := tracing.Start(GetRequestCtx(c), key, trace.WithAttributes(attribute.String("requestId", GetRequestID(c))))
+	defer span.End()
 	mainFunc(ctx)
 }
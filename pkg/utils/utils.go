@@ -1,7 +1,10 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"math/rand"
 	"time"
 
@@ -19,11 +22,10 @@ func RandString(n int) string {
 	return string(b)
 }
 
-// Keep this two config private, it should not expose to open source
-const NBSecretPassword = "A String Very Very Very Strong!!@##$!@#$"
+// Keep this config private, it should not expose to open source
 const NBRandomPassword = "A String Very Very Very Niubilty!!@##$!@#4"
 
-// A Util function to generate jwt_token which can be used in the request header
+// A Util function to generate jwt_token which can be used in the request header, signed with secret
 func GenToken(id uint, sessionId string, secret string) string {
 	jwt_token := jwt.New(jwt.GetSigningMethod("HS256"))
 	// Set some claims
@@ -33,7 +35,7 @@ func GenToken(id uint, sessionId string, secret string) string {
 		"exp":        time.Now().Add(time.Hour * 24).Unix(),
 	}
 	// Sign and get the complete encoded token as a string
-	token, _ := jwt_token.SignedString([]byte(NBSecretPassword))
+	token, _ := jwt_token.SignedString([]byte(secret))
 	return token
 }
 
@@ -48,3 +50,18 @@ func Random64BaseEncodedBytes(size int) string {
 	bytes := RandomBytes(size)
 	return base64.StdEncoding.EncodeToString(bytes)
 }
+
+// Generate a url safe token from a cryptographically secure source
+func GenerateSecureToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := crand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// Hash a token before it is persisted, so a leaked store can't be replayed
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}